@@ -0,0 +1,61 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/OpenDgraph/Otter/internal/config"
+	"gopkg.in/yaml.v2"
+)
+
+const configUsage = `usage: otter config check [-v] [file]
+
+Validates the configuration Otter would start with: file (default
+$CONFIG_FILE) merged with the environment and defaults. Prints the
+effective configuration and exits 0 when valid, prints every problem and
+exits 1 otherwise.
+`
+
+// runConfigCommand implements "otter config ..." and returns the exit code.
+func runConfigCommand(args []string) int {
+	if len(args) == 0 || args[0] != "check" {
+		fmt.Fprint(os.Stderr, configUsage)
+		return 2
+	}
+
+	fs := flag.NewFlagSet("config check", flag.ContinueOnError)
+	fs.Usage = func() { fmt.Fprint(os.Stderr, configUsage) }
+	verbose := fs.Bool("v", false, "print the loading log")
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	file := os.Getenv("CONFIG_FILE")
+	if fs.NArg() > 0 {
+		file = fs.Arg(0)
+		os.Setenv("CONFIG_FILE", file)
+	}
+	if file == "" {
+		file = "environment"
+	}
+	if !*verbose {
+		log.SetOutput(io.Discard)
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", file, err)
+		return 1
+	}
+
+	out, err := yaml.Marshal(cfg.Redacted())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", file, err)
+		return 1
+	}
+	fmt.Printf("# %s is valid. Effective configuration:\n%s", file, out)
+	return 0
+}
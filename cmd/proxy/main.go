@@ -1,14 +1,22 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/OpenDgraph/Otter/internal/config"
-	"github.com/OpenDgraph/Otter/internal/loadbalancer"
 	"github.com/OpenDgraph/Otter/internal/proxy"
 	"github.com/OpenDgraph/Otter/internal/routing"
+	"github.com/OpenDgraph/Otter/internal/server"
+	"github.com/OpenDgraph/Otter/internal/tlsconfig"
 	"github.com/OpenDgraph/Otter/internal/websocket"
 )
 
@@ -16,26 +24,22 @@ var (
 	proxyInstance *proxy.Proxy
 )
 
+const (
+	configWatchInterval    = 2 * time.Second
+	defaultShutdownTimeout = 30 * time.Second
+)
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		os.Exit(runConfigCommand(os.Args[2:]))
+	}
+
 	cfg, err := config.LoadConfig()
 	if err != nil {
 		log.Fatalf("Error loading config: %v", err)
 	}
 
-	switch cfg.BalancerType {
-	case "defined", "purposeful":
-		balancer := loadbalancer.NewPurposefulBalancer(*cfg)
-		fmt.Println("Using purposeful balancer")
-		proxyInstance, err = proxy.NewPurposefulProxy(balancer, *cfg)
-	default:
-		var balancer loadbalancer.Balancer
-		balancer, err = loadbalancer.NewBalancer(*cfg)
-		if err != nil {
-			log.Fatalf("Error creating balancer: %v", err)
-		}
-		proxyInstance, err = proxy.NewProxy(balancer, *cfg)
-	}
-
+	proxyInstance, err = proxy.New(*cfg)
 	if err != nil {
 		log.Fatalf("Error creating proxy: %v", err)
 	}
@@ -44,32 +48,133 @@ func main() {
 		log.Fatal("proxy instance is nil")
 	}
 
+	go watchConfig(proxyInstance)
+
+	tlsConfig, err := tlsconfig.Server(cfg.TLS)
+	if err != nil {
+		log.Fatalf("Error configuring TLS: %v", err)
+	}
+
+	hub := websocket.NewHub()
+	servers := server.NewGroup()
+
 	// Proxy HTTP server
-	if cfg.EnableHTTP != nil {
+	if cfg.HTTPEnabled() {
 		mux := http.NewServeMux()
 		mux.Handle("/", routing.SetupRoutes(proxyInstance))
-
-		log.Printf("Starting proxy server on port %d\n", cfg.ProxyPort)
-
-		go func() {
-			log.Fatal(http.ListenAndServe(fmt.Sprintf(":%d", cfg.ProxyPort), mux))
-		}()
+		servers.Add("proxy", &http.Server{Addr: fmt.Sprintf(":%d", cfg.ProxyPort), Handler: mux, TLSConfig: tlsConfig})
 	} else {
 		log.Println("HTTP proxy server disabled.")
 	}
 
 	// WebSocket server
-	if cfg.EnableWebSocket != nil {
+	if cfg.WebSocketEnabled() {
 		wsMux := http.NewServeMux()
-		wsMux.HandleFunc("/ws", websocket.HandleWebSocketWithProxy(proxyInstance))
-		log.Printf("Starting websocket server on port %d\n", cfg.WebSocketPort)
-		log.Fatal(http.ListenAndServe(fmt.Sprintf(":%d", cfg.WebSocketPort), wsMux))
+		wsMux.HandleFunc("/ws", websocket.HandleWebSocketWithProxy(proxyInstance, hub))
+		servers.Add("websocket", &http.Server{Addr: fmt.Sprintf(":%d", cfg.WebSocketPort), Handler: wsMux, TLSConfig: http1Only(tlsConfig)})
 	} else {
 		log.Println("WebSocket server disabled.")
 	}
 
-	if cfg.EnableHTTP != nil && cfg.EnableWebSocket != nil {
+	if servers.Len() == 0 {
 		log.Fatal("Both HTTP and WebSocket servers are disabled. Nothing to run.")
 	}
+	if err := servers.Start(); err != nil {
+		proxyInstance.Close(context.Background())
+		log.Fatalf("Error starting servers: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	failure := servers.Wait(ctx)
+	stop()
+
+	timeout := cfg.ShutdownTimeout
+	if timeout <= 0 {
+		timeout = defaultShutdownTimeout
+	}
+	if failure != nil {
+		log.Printf("Error: %v, shutting down (timeout %s)", failure, timeout)
+	} else {
+		log.Printf("Received shutdown signal, shutting down (timeout %s)", timeout)
+	}
+	shutdown(servers, hub, proxyInstance, timeout)
+	if failure != nil {
+		os.Exit(1)
+	}
+}
+
+// http1Only keeps the WebSocket listener off HTTP/2, where the upgrade
+// handshake does not exist.
+func http1Only(tlsConfig *tls.Config) *tls.Config {
+	if tlsConfig == nil {
+		return nil
+	}
+	tlsConfig = tlsConfig.Clone()
+	tlsConfig.NextProtos = []string{"http/1.1"}
+	return tlsConfig
+}
+
+// shutdown stops accepting connections, drains HTTP requests and WebSocket
+// sessions, waits for the Dgraph calls still running and closes the clients,
+// all within timeout.
+func shutdown(servers *server.Group, hub *websocket.Hub, p *proxy.Proxy, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		if err := servers.Shutdown(ctx); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		if err := hub.Shutdown(ctx); err != nil {
+			log.Printf("Warning: WebSocket sessions did not drain: %v", err)
+		}
+	}()
+	wg.Wait()
+
+	if err := p.Close(ctx); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	log.Println("Shutdown complete.")
+}
 
+// watchConfig reloads the proxy when CONFIG_FILE changes or on SIGHUP.
+func watchConfig(p *proxy.Proxy) {
+	reload := make(chan string, 1)
+	trigger := func(reason string) {
+		select {
+		case reload <- reason:
+		default: // a reload is already pending
+		}
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			trigger("SIGHUP")
+		}
+	}()
+
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		go config.WatchFile(path, configWatchInterval, nil, func() { trigger(path + " changed") })
+	}
+
+	for reason := range reload {
+		log.Printf("Reloading configuration (%s)", reason)
+		cfg, err := config.LoadConfig()
+		if err != nil {
+			log.Printf("Error reloading config, keeping the current one: %v", err)
+			continue
+		}
+		if err := p.Reload(*cfg); err != nil {
+			log.Printf("Error applying reloaded config, keeping the current one: %v", err)
+		}
+	}
 }
@@ -51,6 +51,7 @@ func TestHTTPAPI(t *testing.T) {
 		}
 
 		req.Header.Set("Content-Type", "application/dql")
+		req.Header.Set("Authorization", "Bearer banana")
 
 		resp, err := client.Do(req)
 		if err != nil {
@@ -87,6 +88,7 @@ func TestHTTPAPI(t *testing.T) {
 		}
 
 		req.Header.Set("Content-Type", "application/dql")
+		req.Header.Set("Authorization", "Bearer banana")
 
 		resp, err := client.Do(req)
 		if err != nil {
@@ -0,0 +1,205 @@
+// Package admission caps the calls in flight to a backend. Calls over the
+// cap wait in a bounded queue, higher priorities first and in arrival order
+// otherwise, so a burst is smoothed instead of forwarded. Batch calls can be
+// kept to a share of the cap and refused beyond it, so they are the first to
+// go when the cap shrinks under load.
+package admission
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	ErrQueueFull    = errors.New("too many calls waiting for the backend")
+	ErrQueueTimeout = errors.New("timed out waiting for the backend")
+	ErrShed         = errors.New("backend overloaded, batch call shed")
+)
+
+// Options configure a Limiter.
+type Options struct {
+	Limit        int // calls in flight, 0 for no cap
+	QueueSize    int // calls waiting beyond Limit
+	QueueTimeout time.Duration
+	BatchShare   float64 // share of Limit batch calls may take, 0 for all of it
+}
+
+// Limiter admits up to its limit of calls at once. A nil *Limiter admits
+// every call.
+type Limiter struct {
+	mu       sync.Mutex
+	opts     Options
+	inflight int
+	waiting  queue
+	arrivals uint64
+}
+
+func New(opts Options) *Limiter {
+	return &Limiter{opts: opts}
+}
+
+// Configure changes the settings of l. Calls in flight stay admitted and
+// waiting calls are admitted at once when the limit grew.
+func (l *Limiter) Configure(opts Options) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.opts = opts
+	l.admit()
+}
+
+// SetLimit changes the limit of l alone, as Configure does.
+func (l *Limiter) SetLimit(limit int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.opts.Limit = limit
+	l.admit()
+}
+
+// Limit returns the number of calls l admits at once, 0 for no cap.
+func (l *Limiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.opts.Limit
+}
+
+// Acquire admits a call of priority, waiting in the queue when l is at its
+// limit. It fails with ErrQueueFull, ErrQueueTimeout or the error of ctx,
+// and with ErrShed for a batch call beyond its share, which never waits.
+// An admitted call must be released.
+func (l *Limiter) Acquire(ctx context.Context, priority Priority) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	if priority < Interactive && l.opts.BatchShare > 0 && l.opts.Limit > 0 {
+		defer l.mu.Unlock()
+		if l.inflight >= l.batchLimit() || len(l.waiting) > 0 {
+			return ErrShed
+		}
+		l.inflight++
+		return nil
+	}
+	if l.free() && len(l.waiting) == 0 {
+		l.inflight++
+		l.mu.Unlock()
+		return nil
+	}
+	if len(l.waiting) >= l.opts.QueueSize {
+		l.mu.Unlock()
+		return ErrQueueFull
+	}
+	l.arrivals++
+	w := &waiter{priority: priority, arrival: l.arrivals, ready: make(chan struct{})}
+	heap.Push(&l.waiting, w)
+	timeout := l.opts.QueueTimeout
+	l.mu.Unlock()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	var err error
+	select {
+	case <-w.ready:
+		return nil
+	case <-timer.C:
+		err = ErrQueueTimeout
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if w.index < 0 {
+		// Admitted while giving up: take the slot.
+		return nil
+	}
+	heap.Remove(&l.waiting, w.index)
+	return err
+}
+
+// Release ends an admitted call and admits the next waiting one.
+func (l *Limiter) Release() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.inflight > 0 {
+		l.inflight--
+	}
+	l.admit()
+}
+
+// Inflight returns the number of admitted calls not released yet.
+func (l *Limiter) Inflight() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.inflight
+}
+
+// Waiting returns the number of calls in the queue.
+func (l *Limiter) Waiting() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.waiting)
+}
+
+func (l *Limiter) free() bool {
+	return l.opts.Limit <= 0 || l.inflight < l.opts.Limit
+}
+
+// batchLimit is the number of calls in flight up to which batch calls are
+// admitted. l.mu is held.
+func (l *Limiter) batchLimit() int {
+	return max(1, int(float64(l.opts.Limit)*l.opts.BatchShare))
+}
+
+// admit lets waiting calls in while there is room. l.mu is held.
+func (l *Limiter) admit() {
+	for len(l.waiting) > 0 && l.free() {
+		w := heap.Pop(&l.waiting).(*waiter)
+		l.inflight++
+		close(w.ready)
+	}
+}
+
+type waiter struct {
+	priority Priority
+	arrival  uint64
+	ready    chan struct{} // closed once admitted
+	index    int           // in the queue, -1 once out of it
+}
+
+// queue is a heap of waiters, highest priority then earliest arrival first.
+type queue []*waiter
+
+func (q queue) Len() int { return len(q) }
+
+func (q queue) Less(i, j int) bool {
+	if q[i].priority != q[j].priority {
+		return q[i].priority > q[j].priority
+	}
+	return q[i].arrival < q[j].arrival
+}
+
+func (q queue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index, q[j].index = i, j
+}
+
+func (q *queue) Push(x any) {
+	w := x.(*waiter)
+	w.index = len(*q)
+	*q = append(*q, w)
+}
+
+func (q *queue) Pop() any {
+	old := *q
+	w := old[len(old)-1]
+	old[len(old)-1] = nil
+	w.index = -1
+	*q = old[:len(old)-1]
+	return w
+}
@@ -0,0 +1,58 @@
+package admission
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAcquireQueuesByPriority(t *testing.T) {
+	l := New(Options{Limit: 1, QueueSize: 10, QueueTimeout: time.Minute})
+	require.NoError(t, l.Acquire(context.Background(), 0))
+
+	order := make(chan int, 3)
+	wait := func(id int, priority Priority) {
+		go func() {
+			if l.Acquire(context.Background(), priority) == nil {
+				order <- id
+			}
+		}()
+		require.Eventually(t, func() bool { return l.Waiting() == id }, time.Second, time.Millisecond)
+	}
+	wait(1, 0)
+	wait(2, 0)
+	wait(3, 5)
+
+	for _, want := range []int{3, 1, 2} {
+		l.Release()
+		require.Equal(t, want, <-order)
+		require.Equal(t, 1, l.Inflight())
+	}
+}
+
+func TestAcquireRejects(t *testing.T) {
+	l := New(Options{Limit: 1, QueueSize: 1, QueueTimeout: 20 * time.Millisecond})
+	require.NoError(t, l.Acquire(context.Background(), 0))
+
+	require.ErrorIs(t, l.Acquire(context.Background(), 0), ErrQueueTimeout)
+	require.Zero(t, l.Waiting())
+
+	l.Configure(Options{Limit: 1, QueueSize: 1, QueueTimeout: time.Minute})
+	ctx, cancel := context.WithCancel(context.Background())
+	queued := make(chan error)
+	go func() { queued <- l.Acquire(ctx, 0) }()
+	require.Eventually(t, func() bool { return l.Waiting() == 1 }, time.Second, time.Millisecond)
+	require.ErrorIs(t, l.Acquire(context.Background(), 0), ErrQueueFull)
+	cancel()
+	require.ErrorIs(t, <-queued, context.Canceled)
+
+	l.SetLimit(2)
+	require.NoError(t, l.Acquire(context.Background(), 0), "the raised limit has room")
+	require.Equal(t, 2, l.Inflight())
+
+	var none *Limiter
+	require.NoError(t, none.Acquire(context.Background(), 0))
+	none.Release()
+}
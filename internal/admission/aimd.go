@@ -0,0 +1,67 @@
+package admission
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/OpenDgraph/Otter/internal/config"
+)
+
+// AIMD adapts a concurrency limit to the health of a backend: the limit
+// grows by one for every limit's worth of calls answered within the latency
+// target and shrinks by the backoff factor when a call fails or is late. A
+// burst of failures of calls that ran at the same time shrinks it once.
+type AIMD struct {
+	mu        sync.Mutex
+	cfg       config.AdaptiveConfig
+	max       int
+	limit     float64
+	decreased time.Time
+}
+
+// NewAIMD starts at cfg.InitialLimit and never goes beyond max.
+func NewAIMD(cfg config.AdaptiveConfig, max int) *AIMD {
+	a := &AIMD{}
+	a.Configure(cfg, max)
+	a.limit = a.clamp(float64(a.cfg.InitialLimit))
+	return a
+}
+
+// Configure changes the bounds and targets of a, keeping its limit within
+// the new bounds.
+func (a *AIMD) Configure(cfg config.AdaptiveConfig, max int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.cfg, a.max = cfg.WithDefaults(), max
+	a.limit = a.clamp(a.limit)
+}
+
+// Observe feeds the outcome of a call that took elapsed back into a and
+// returns the new limit.
+func (a *AIMD) Observe(elapsed time.Duration, failed bool, now time.Time) int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if failed || elapsed > a.cfg.Latency {
+		// A call that started before the last decrease answers for the
+		// same overload.
+		if now.Sub(a.decreased) >= elapsed {
+			a.limit = a.clamp(a.limit * a.cfg.Backoff)
+			a.decreased = now
+		}
+	} else {
+		a.limit = a.clamp(a.limit + 1/a.limit)
+	}
+	return int(a.limit)
+}
+
+// Limit returns the current limit.
+func (a *AIMD) Limit() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return int(a.limit)
+}
+
+func (a *AIMD) clamp(limit float64) float64 {
+	return math.Max(float64(a.cfg.MinLimit), math.Min(float64(a.max), limit))
+}
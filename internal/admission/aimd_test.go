@@ -0,0 +1,52 @@
+package admission
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/OpenDgraph/Otter/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAIMD(t *testing.T) {
+	a := NewAIMD(config.AdaptiveConfig{InitialLimit: 10, MinLimit: 2, Latency: 100 * time.Millisecond, Backoff: 0.5}, 12)
+	now := time.Now()
+	require.Equal(t, 10, a.Limit())
+
+	for range 11 {
+		a.Observe(10*time.Millisecond, false, now)
+	}
+	require.Equal(t, 11, a.Limit(), "about one more per limit's worth of fast calls")
+
+	require.Equal(t, 5, a.Observe(10*time.Millisecond, true, now))
+	require.Equal(t, 5, a.Observe(time.Second, false, now.Add(500*time.Millisecond)), "a call running at the last decrease does not count again")
+	require.Equal(t, 2, a.Observe(time.Second, false, now.Add(2*time.Second)))
+	require.Equal(t, 2, a.Observe(time.Second, true, now.Add(4*time.Second)), "never below the minimum")
+
+	for range 100 {
+		a.Observe(time.Millisecond, false, now)
+	}
+	require.Equal(t, 12, a.Limit(), "never above the maximum")
+	a.Configure(config.AdaptiveConfig{MinLimit: 2}, 8)
+	require.Equal(t, 8, a.Limit())
+}
+
+func TestAcquireShedsBatch(t *testing.T) {
+	l := New(Options{Limit: 4, QueueSize: 10, QueueTimeout: time.Minute, BatchShare: 0.5})
+	ctx := context.Background()
+
+	require.NoError(t, l.Acquire(ctx, Batch))
+	require.NoError(t, l.Acquire(ctx, Batch))
+	require.ErrorIs(t, l.Acquire(ctx, Batch), ErrShed, "batch calls take half the limit")
+	require.NoError(t, l.Acquire(ctx, Interactive))
+	require.NoError(t, l.Acquire(ctx, Critical))
+
+	l.SetLimit(2)
+	l.Release()
+	l.Release()
+	require.ErrorIs(t, l.Acquire(ctx, Batch), ErrShed, "a shrunk limit sheds batch calls first")
+
+	_, err := ParsePriority("urgent")
+	require.Error(t, err)
+}
@@ -0,0 +1,35 @@
+package admission
+
+import "fmt"
+
+// Priority orders the calls waiting for a backend.
+type Priority int
+
+const (
+	Batch       Priority = -1 // analytics and other work that can be retried later
+	Interactive Priority = 0  // the default
+	Critical    Priority = 1
+)
+
+// ParsePriority reads the name of a priority; an empty one is Interactive.
+func ParsePriority(name string) (Priority, error) {
+	switch name {
+	case "batch":
+		return Batch, nil
+	case "", "interactive":
+		return Interactive, nil
+	case "critical":
+		return Critical, nil
+	}
+	return Interactive, fmt.Errorf("unknown priority %q, expected batch, interactive or critical", name)
+}
+
+func (p Priority) String() string {
+	switch p {
+	case Batch:
+		return "batch"
+	case Critical:
+		return "critical"
+	}
+	return "interactive"
+}
@@ -0,0 +1,52 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/OpenDgraph/Otter/internal/config"
+	"gopkg.in/yaml.v2"
+)
+
+// APIKeys accepts static keys. Keys are held as SHA-256 digests so the
+// lookup does not compare secrets byte by byte.
+type APIKeys struct {
+	keys map[[sha256.Size]byte]*Identity
+}
+
+// NewAPIKeys loads keys and, when file is set, the keys listed there.
+func NewAPIKeys(keys []config.APIKey, file string) (*APIKeys, error) {
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("reading API keys: %w", err)
+		}
+		var fromFile []config.APIKey
+		if err := yaml.UnmarshalStrict(data, &fromFile); err != nil {
+			return nil, fmt.Errorf("parsing API keys in %s: %w", file, err)
+		}
+		keys = append(append([]config.APIKey{}, keys...), fromFile...)
+	}
+
+	a := &APIKeys{keys: make(map[[sha256.Size]byte]*Identity, len(keys))}
+	for _, key := range keys {
+		if key.Key == "" {
+			return nil, errors.New("API key without key")
+		}
+		a.keys[sha256.Sum256([]byte(key.Key))] = &Identity{Subject: key.Subject, Groups: key.Groups, Method: "api_key", Tenant: key.Tenant}
+	}
+	return a, nil
+}
+
+func (a *APIKeys) Authenticate(_ context.Context, creds Credentials) (*Identity, error) {
+	if creds.Token == "" {
+		return nil, ErrNoCredentials
+	}
+	if id, ok := a.keys[sha256.Sum256([]byte(creds.Token))]; ok {
+		return id, nil
+	}
+	return nil, errors.New("unknown API key")
+}
@@ -0,0 +1,122 @@
+// Package auth authenticates Otter's clients: static API keys, JWTs
+// validated locally and Dgraph ACL logins.
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/OpenDgraph/Otter/internal/config"
+)
+
+var (
+	// ErrNoCredentials is returned by an Authenticator when the credentials
+	// are not of a kind it handles, so the next one can be tried.
+	ErrNoCredentials = errors.New("no credentials")
+	// ErrUnauthenticated is returned when no authenticator accepted the
+	// credentials.
+	ErrUnauthenticated = errors.New("unauthenticated")
+)
+
+// Credentials are what a client presents: a token (API key or JWT) or a
+// Dgraph user and password.
+type Credentials struct {
+	Token    string
+	User     string
+	Password string
+}
+
+// Identity is an authenticated client.
+type Identity struct {
+	Subject   string
+	Groups    []string
+	Method    string // api_key, jwt or dgraph
+	Namespace uint64 // Dgraph namespace of a dgraph login
+	Tenant    string // tenant named by the credentials, if any
+
+	// Dgraph holds the credentials of a dgraph login, so the proxy can act
+	// as that user.
+	Dgraph *Credentials
+}
+
+// InGroup reports whether the identity belongs to group.
+func (id *Identity) InGroup(group string) bool {
+	for _, g := range id.Groups {
+		if g == group {
+			return true
+		}
+	}
+	return false
+}
+
+type Authenticator interface {
+	// Authenticate returns the identity creds belong to, ErrNoCredentials
+	// when it does not handle them, or an error saying why they are refused.
+	Authenticate(ctx context.Context, creds Credentials) (*Identity, error)
+}
+
+// Chain tries every authenticator in turn and returns the first identity
+// one of them accepts.
+type Chain []Authenticator
+
+func (c Chain) Authenticate(ctx context.Context, creds Credentials) (*Identity, error) {
+	var errs []error
+	for _, a := range c {
+		id, err := a.Authenticate(ctx, creds)
+		if err == nil {
+			return id, nil
+		}
+		if !errors.Is(err, ErrNoCredentials) {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) == 0 {
+		return nil, ErrUnauthenticated
+	}
+	return nil, fmt.Errorf("%w: %w", ErrUnauthenticated, errors.Join(errs...))
+}
+
+// New builds the authenticators enabled in cfg. login performs Dgraph ACL
+// logins; it is only used with cfg.Dgraph.
+func New(cfg config.AuthConfig, login LoginFunc) (Authenticator, error) {
+	var chain Chain
+	if len(cfg.APIKeys) > 0 || cfg.APIKeysFile != "" {
+		keys, err := NewAPIKeys(cfg.APIKeys, cfg.APIKeysFile)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, keys)
+	}
+	if cfg.JWT.Enabled() {
+		jwt, err := NewJWT(cfg.JWT)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, jwt)
+	}
+	if cfg.Dgraph {
+		chain = append(chain, NewDgraph(login))
+	}
+	if len(chain) == 0 {
+		log.Printf("Warning: No authentication method configured, clients cannot authenticate.")
+	}
+	return chain, nil
+}
+
+type identityCtxKey struct{}
+
+// WithIdentity attaches the identity of the caller to ctx.
+func WithIdentity(ctx context.Context, id *Identity) context.Context {
+	if id == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, identityCtxKey{}, id)
+}
+
+// IdentityFrom returns the identity attached to ctx, or nil.
+func IdentityFrom(ctx context.Context) *Identity {
+	id, _ := ctx.Value(identityCtxKey{}).(*Identity)
+	return id
+}
@@ -0,0 +1,162 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/OpenDgraph/Otter/internal/config"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIKeys(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "keys.yaml")
+	require.NoError(t, os.WriteFile(file, []byte("- {key: s3cret, subject: ci, groups: [deploy], tenant: acme}\n"), 0600))
+
+	keys, err := NewAPIKeys([]config.APIKey{{Key: "banana", Subject: "demo"}}, file)
+	require.NoError(t, err)
+
+	id, err := keys.Authenticate(context.Background(), Credentials{Token: "s3cret"})
+	require.NoError(t, err)
+	require.Equal(t, &Identity{Subject: "ci", Groups: []string{"deploy"}, Method: "api_key", Tenant: "acme"}, id)
+	require.True(t, id.InGroup("deploy"))
+
+	_, err = keys.Authenticate(context.Background(), Credentials{Token: "apple"})
+	require.Error(t, err)
+	_, err = keys.Authenticate(context.Background(), Credentials{User: "groot"})
+	require.ErrorIs(t, err, ErrNoCredentials)
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, key interface{}, kid string, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	s, err := token.SignedString(key)
+	require.NoError(t, err)
+	return s
+}
+
+func TestJWT(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	jwks, _ := json.Marshal(map[string]interface{}{"keys": []map[string]string{{
+		"kty": "RSA", "kid": "k1", "use": "sig",
+		"n": base64.RawURLEncoding.EncodeToString(rsaKey.N.Bytes()),
+		"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(rsaKey.E)).Bytes()),
+	}}})
+	jwksFile := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(jwksFile, jwks, 0600))
+
+	j, err := NewJWT(config.JWTConfig{HMACSecret: "shh", JWKSFile: jwksFile, Issuer: "https://idp", GroupsClaim: "roles"})
+	require.NoError(t, err)
+	exp := time.Now().Add(time.Hour).Unix()
+	authenticate := func(token string) (*Identity, error) {
+		return j.Authenticate(context.Background(), Credentials{Token: token})
+	}
+
+	id, err := authenticate(sign(t, jwt.SigningMethodHS256, []byte("shh"), "", jwt.MapClaims{"sub": "alice", "iss": "https://idp", "exp": exp, "roles": []string{"reader"}}))
+	require.NoError(t, err)
+	require.Equal(t, &Identity{Subject: "alice", Groups: []string{"reader"}, Method: "jwt"}, id)
+
+	id, err = authenticate(sign(t, jwt.SigningMethodRS256, rsaKey, "k1", jwt.MapClaims{"sub": "bob", "iss": "https://idp", "exp": exp, "roles": "reader writer", "tenant": "acme"}))
+	require.NoError(t, err)
+	require.Equal(t, []string{"reader", "writer"}, id.Groups)
+	require.Equal(t, "acme", id.Tenant)
+
+	for name, token := range map[string]string{
+		"wrong secret": sign(t, jwt.SigningMethodHS256, []byte("nope"), "", jwt.MapClaims{"sub": "alice", "iss": "https://idp", "exp": exp}),
+		"expired":      sign(t, jwt.SigningMethodHS256, []byte("shh"), "", jwt.MapClaims{"sub": "alice", "iss": "https://idp", "exp": time.Now().Add(-time.Minute).Unix()}),
+		"no expiry":    sign(t, jwt.SigningMethodHS256, []byte("shh"), "", jwt.MapClaims{"sub": "alice", "iss": "https://idp"}),
+		"wrong issuer": sign(t, jwt.SigningMethodHS256, []byte("shh"), "", jwt.MapClaims{"sub": "alice", "iss": "https://evil", "exp": exp}),
+		"unknown kid":  sign(t, jwt.SigningMethodRS256, rsaKey, "k2", jwt.MapClaims{"sub": "bob", "iss": "https://idp", "exp": exp}),
+		"missing sub":  sign(t, jwt.SigningMethodHS256, []byte("shh"), "", jwt.MapClaims{"iss": "https://idp", "exp": exp}),
+		"none alg":     sign(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, "", jwt.MapClaims{"sub": "alice", "iss": "https://idp", "exp": exp}),
+	} {
+		_, err := authenticate(token)
+		require.Error(t, err, name)
+		require.NotErrorIs(t, err, ErrNoCredentials, name)
+	}
+
+	_, err = authenticate("banana")
+	require.ErrorIs(t, err, ErrNoCredentials, "not a JWT")
+}
+
+func TestDgraph(t *testing.T) {
+	token := sign(t, jwt.SigningMethodHS256, []byte("dgraph's key"), "", jwt.MapClaims{"userid": "groot", "groups": []string{"guardians"}, "namespace": 2})
+	d := NewDgraph(func(ctx context.Context, user, password string) (string, error) {
+		if user != "groot" || password != "password" {
+			return "", errors.New("invalid username or password")
+		}
+		return token, nil
+	})
+
+	id, err := d.Authenticate(context.Background(), Credentials{User: "groot", Password: "password"})
+	require.NoError(t, err)
+	require.Equal(t, &Identity{Subject: "groot", Groups: []string{"guardians"}, Method: "dgraph", Namespace: 2,
+		Dgraph: &Credentials{User: "groot", Password: "password"}}, id)
+
+	_, err = d.Authenticate(context.Background(), Credentials{User: "groot", Password: "wrong"})
+	require.ErrorContains(t, err, "invalid username or password")
+	_, err = d.Authenticate(context.Background(), Credentials{Token: "banana"})
+	require.ErrorIs(t, err, ErrNoCredentials)
+}
+
+func TestDgraphRemembersLogins(t *testing.T) {
+	now := time.Unix(1000, 0)
+	token := sign(t, jwt.SigningMethodHS256, []byte("dgraph's key"), "", jwt.MapClaims{"userid": "groot", "exp": now.Add(30 * time.Second).Unix()})
+	logins := 0
+	d := NewDgraph(func(ctx context.Context, user, password string) (string, error) {
+		logins++
+		if password != "password" {
+			return "", errors.New("invalid username or password")
+		}
+		return token, nil
+	})
+	d.now = func() time.Time { return now }
+	authenticate := func(password string) error {
+		_, err := d.Authenticate(context.Background(), Credentials{User: "groot", Password: password})
+		return err
+	}
+
+	require.NoError(t, authenticate("password"))
+	require.NoError(t, authenticate("password"))
+	require.Equal(t, 1, logins, "a verified login is remembered")
+
+	require.Error(t, authenticate("wrong"))
+	require.Error(t, authenticate("wrong"))
+	require.Equal(t, 3, logins, "failed logins are not")
+
+	now = now.Add(30 * time.Second)
+	require.NoError(t, authenticate("password"))
+	require.Equal(t, 4, logins, "a login is trusted no longer than its token")
+}
+
+func TestChain(t *testing.T) {
+	authn, err := New(config.AuthConfig{APIKeys: []config.APIKey{{Key: "banana", Subject: "demo"}}, JWT: config.JWTConfig{HMACSecret: "shh"}}, nil)
+	require.NoError(t, err)
+
+	token := sign(t, jwt.SigningMethodHS256, []byte("shh"), "", jwt.MapClaims{"sub": "alice", "exp": time.Now().Add(time.Hour).Unix()})
+	id, err := authn.Authenticate(context.Background(), Credentials{Token: token})
+	require.NoError(t, err, "an unknown API key does not stop the JWT check")
+	require.Equal(t, "alice", id.Subject)
+
+	_, err = authn.Authenticate(context.Background(), Credentials{Token: "apple"})
+	require.ErrorIs(t, err, ErrUnauthenticated)
+	_, err = authn.Authenticate(context.Background(), Credentials{User: "groot", Password: "password"})
+	require.ErrorIs(t, err, ErrUnauthenticated, "dgraph login is not enabled")
+
+	none, err := New(config.AuthConfig{}, nil)
+	require.NoError(t, err)
+	_, err = none.Authenticate(context.Background(), Credentials{Token: "banana"})
+	require.ErrorIs(t, err, ErrUnauthenticated)
+}
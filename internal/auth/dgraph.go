@@ -0,0 +1,122 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// LoginFunc logs in to Dgraph with an ACL user and returns its access JWT.
+type LoginFunc func(ctx context.Context, user, password string) (string, error)
+
+// dgraphLoginTTL is how long a verified Dgraph login is trusted without
+// logging in again, and dgraphLogins how many are remembered at most.
+const (
+	dgraphLoginTTL = time.Minute
+	dgraphLogins   = 10000
+)
+
+// Dgraph authenticates Dgraph ACL users by logging them in to the cluster.
+// The identity takes the user's groups and namespace from the access JWT
+// Dgraph returns. Successful logins are remembered for dgraphLoginTTL, or
+// until their token expires, so callers sending Basic credentials on every
+// request do not log in each time; a password changed in Dgraph is still
+// accepted until then.
+type Dgraph struct {
+	login LoginFunc
+
+	mu       sync.Mutex
+	verified map[[sha256.Size]byte]verifiedLogin // by hash of user and password
+	now      func() time.Time
+}
+
+type verifiedLogin struct {
+	id      Identity
+	expires time.Time
+}
+
+func NewDgraph(login LoginFunc) *Dgraph {
+	return &Dgraph{login: login, verified: make(map[[sha256.Size]byte]verifiedLogin), now: time.Now}
+}
+
+func (d *Dgraph) Authenticate(ctx context.Context, creds Credentials) (*Identity, error) {
+	if creds.User == "" {
+		return nil, ErrNoCredentials
+	}
+	if d.login == nil {
+		return nil, errors.New("Dgraph login is not available")
+	}
+	key := sha256.Sum256([]byte(creds.User + "\x00" + creds.Password))
+	if id, ok := d.lookup(key); ok {
+		return id, nil
+	}
+	token, err := d.login(ctx, creds.User, creds.Password)
+	if err != nil {
+		return nil, fmt.Errorf("Dgraph login failed: %w", err)
+	}
+
+	// The token comes straight from Dgraph, which holds the key to verify it.
+	var claims struct {
+		UserID    string   `json:"userid"`
+		Groups    []string `json:"groups"`
+		Namespace uint64   `json:"namespace"`
+		jwt.RegisteredClaims
+	}
+	if _, _, err := jwt.NewParser().ParseUnverified(token, &claims); err != nil {
+		return nil, fmt.Errorf("Dgraph login returned an invalid token: %w", err)
+	}
+	subject := claims.UserID
+	if subject == "" {
+		subject = creds.User
+	}
+	id := Identity{
+		Subject:   subject,
+		Groups:    claims.Groups,
+		Method:    "dgraph",
+		Namespace: claims.Namespace,
+		Dgraph:    &Credentials{User: creds.User, Password: creds.Password},
+	}
+	expires := d.now().Add(dgraphLoginTTL)
+	if claims.ExpiresAt != nil && claims.ExpiresAt.Before(expires) {
+		expires = claims.ExpiresAt.Time
+	}
+	d.remember(key, verifiedLogin{id: id, expires: expires})
+	return &id, nil
+}
+
+// lookup returns the identity of a login verified under key that has not
+// expired.
+func (d *Dgraph) lookup(key [sha256.Size]byte) (*Identity, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	v, ok := d.verified[key]
+	if !ok || !d.now().Before(v.expires) {
+		return nil, false
+	}
+	id := v.id
+	return &id, true
+}
+
+// remember keeps v under key. Expired logins make room when dgraphLogins
+// are kept; if none has, v is not kept.
+func (d *Dgraph) remember(key [sha256.Size]byte, v verifiedLogin) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.verified) >= dgraphLogins {
+		now := d.now()
+		for k, old := range d.verified {
+			if !now.Before(old.expires) {
+				delete(d.verified, k)
+			}
+		}
+		if len(d.verified) >= dgraphLogins {
+			return
+		}
+	}
+	d.verified[key] = v
+}
@@ -0,0 +1,27 @@
+package auth
+
+import (
+	"net/http"
+	"strings"
+)
+
+// TokenHeader carries an API key or JWT when Authorization is used for
+// something else.
+const TokenHeader = "X-Otter-Token"
+
+// FromRequest reads the credentials of an HTTP request: a bearer token or
+// Basic user and password in Authorization, or a token in TokenHeader. It
+// also returns the header they came from.
+func FromRequest(r *http.Request) (Credentials, string, bool) {
+	if token := r.Header.Get(TokenHeader); token != "" {
+		return Credentials{Token: token}, TokenHeader, true
+	}
+	authz := r.Header.Get("Authorization")
+	if scheme, token, ok := strings.Cut(authz, " "); ok && strings.EqualFold(scheme, "Bearer") {
+		return Credentials{Token: strings.TrimSpace(token)}, "Authorization", true
+	}
+	if user, password, ok := r.BasicAuth(); ok {
+		return Credentials{User: user, Password: password}, "Authorization", true
+	}
+	return Credentials{}, "", false
+}
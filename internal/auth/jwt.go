@@ -0,0 +1,154 @@
+package auth
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/OpenDgraph/Otter/internal/config"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// JWT accepts bearer JWTs signed with an HMAC secret or with an RSA key of
+// a JWKS file, selected by the token's kid.
+type JWT struct {
+	cfg     config.JWTConfig
+	secret  []byte
+	rsaKeys map[string]*rsa.PublicKey // by kid
+	parser  *jwt.Parser
+}
+
+func NewJWT(cfg config.JWTConfig) (*JWT, error) {
+	j := &JWT{cfg: cfg.WithDefaults(), secret: []byte(cfg.HMACSecret)}
+	if cfg.HMACSecretFile != "" {
+		secret, err := os.ReadFile(cfg.HMACSecretFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading JWT secret: %w", err)
+		}
+		j.secret = []byte(strings.TrimSpace(string(secret)))
+	}
+	if cfg.JWKSFile != "" {
+		keys, err := loadJWKS(cfg.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+		j.rsaKeys = keys
+	}
+
+	var methods []string
+	if len(j.secret) > 0 {
+		methods = append(methods, "HS256", "HS384", "HS512")
+	}
+	if len(j.rsaKeys) > 0 {
+		methods = append(methods, "RS256", "RS384", "RS512")
+	}
+	if len(methods) == 0 {
+		return nil, errors.New("JWT authentication needs a secret or a JWKS file with RSA keys")
+	}
+	opts := []jwt.ParserOption{jwt.WithValidMethods(methods), jwt.WithExpirationRequired()}
+	if j.cfg.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(j.cfg.Issuer))
+	}
+	if j.cfg.Audience != "" {
+		opts = append(opts, jwt.WithAudience(j.cfg.Audience))
+	}
+	j.parser = jwt.NewParser(opts...)
+	return j, nil
+}
+
+func (j *JWT) Authenticate(_ context.Context, creds Credentials) (*Identity, error) {
+	if strings.Count(creds.Token, ".") != 2 {
+		return nil, ErrNoCredentials
+	}
+	claims := jwt.MapClaims{}
+	if _, err := j.parser.ParseWithClaims(creds.Token, claims, j.key); err != nil {
+		return nil, fmt.Errorf("invalid JWT: %w", err)
+	}
+	subject, err := claims.GetSubject()
+	if err != nil || subject == "" {
+		return nil, errors.New("invalid JWT: missing sub claim")
+	}
+	tenant, _ := claims[j.cfg.TenantClaim].(string)
+	return &Identity{Subject: subject, Groups: stringsClaim(claims[j.cfg.GroupsClaim]), Method: "jwt", Tenant: tenant}, nil
+}
+
+func (j *JWT) key(token *jwt.Token) (interface{}, error) {
+	switch token.Method.(type) {
+	case *jwt.SigningMethodHMAC:
+		return j.secret, nil
+	case *jwt.SigningMethodRSA:
+		kid, _ := token.Header["kid"].(string)
+		if key, ok := j.rsaKeys[kid]; ok {
+			return key, nil
+		}
+		if len(j.rsaKeys) == 1 && kid == "" {
+			for _, key := range j.rsaKeys {
+				return key, nil
+			}
+		}
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+}
+
+// stringsClaim reads a claim holding a list of strings, or a single string
+// of space separated values as OAuth scopes are.
+func stringsClaim(v interface{}) []string {
+	switch v := v.(type) {
+	case string:
+		return strings.Fields(v)
+	case []interface{}:
+		out := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+// loadJWKS reads the RSA keys of a JSON Web Key Set. Keys of other types
+// are skipped.
+func loadJWKS(file string) (map[string]*rsa.PublicKey, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("reading JWKS: %w", err)
+	}
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("parsing JWKS %s: %w", file, err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("parsing JWKS key %q: invalid n: %w", k.Kid, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("parsing JWKS key %q: invalid e: %w", k.Kid, err)
+		}
+		keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+	return keys, nil
+}
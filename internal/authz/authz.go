@@ -0,0 +1,132 @@
+// Package authz decides which operations an authenticated caller may run.
+package authz
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/OpenDgraph/Otter/internal/auth"
+	"github.com/OpenDgraph/Otter/internal/config"
+)
+
+// Operation is something a policy allows or denies.
+type Operation string
+
+const (
+	Read    Operation = "read"
+	Mutate  Operation = "mutate"
+	Upsert  Operation = "upsert"
+	Alter   Operation = "alter"    // schema changes
+	DropAll Operation = "drop_all" // dropping all data
+	Ratel   Operation = "ratel"    // the Ratel UI
+)
+
+// Denied is the error of a refused operation. It encodes to the JSON
+// clients receive.
+type Denied struct {
+	Operation Operation
+	Subject   string
+	Reason    string
+}
+
+func (d *Denied) Error() string {
+	who := d.Subject
+	if who == "" {
+		who = "anonymous caller"
+	}
+	return fmt.Sprintf("forbidden: %s may not %s (%s)", who, d.Operation, d.Reason)
+}
+
+func (d *Denied) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Error     string    `json:"error"`
+		Operation Operation `json:"operation"`
+		Subject   string    `json:"subject,omitempty"`
+		Reason    string    `json:"reason"`
+	}{"forbidden", d.Operation, d.Subject, d.Reason})
+}
+
+// Policy evaluates the rules of an authz config. A nil *Policy allows
+// everything.
+type Policy struct {
+	rules        []config.PolicyRule
+	defaultAllow bool
+}
+
+// New builds the policy of cfg, reading its policy file. It returns nil when
+// no rule is configured.
+func New(cfg config.AuthzConfig) (*Policy, error) {
+	if !cfg.Enabled() {
+		return nil, nil
+	}
+	if cfg.PolicyFile != "" {
+		file, err := config.LoadPolicyFile(cfg.PolicyFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Rules = append(append([]config.PolicyRule{}, cfg.Rules...), file.Rules...)
+		if file.Default != "" {
+			cfg.Default = file.Default
+		}
+	}
+	return &Policy{rules: cfg.Rules, defaultAllow: cfg.Default == config.PolicyAllow}, nil
+}
+
+// Authorize returns nil when id, nil for an anonymous caller, may run op,
+// and a *Denied otherwise.
+func (p *Policy) Authorize(id *auth.Identity, op Operation) error {
+	if p == nil {
+		return nil
+	}
+	allowed := false
+	for _, rule := range p.rules {
+		if !matches(rule, id) {
+			continue
+		}
+		if lists(rule.Deny, op) {
+			return denied(id, op, "denied by policy")
+		}
+		allowed = allowed || lists(rule.Allow, op)
+	}
+	if allowed || p.defaultAllow {
+		return nil
+	}
+	return denied(id, op, "not allowed by any rule")
+}
+
+func denied(id *auth.Identity, op Operation, reason string) *Denied {
+	d := &Denied{Operation: op, Reason: reason}
+	if id != nil {
+		d.Subject = id.Subject
+	}
+	return d
+}
+
+func matches(rule config.PolicyRule, id *auth.Identity) bool {
+	if len(rule.Subjects) == 0 && len(rule.Groups) == 0 {
+		return true
+	}
+	if id == nil {
+		return false
+	}
+	for _, subject := range rule.Subjects {
+		if subject == id.Subject {
+			return true
+		}
+	}
+	for _, group := range rule.Groups {
+		if id.InGroup(group) {
+			return true
+		}
+	}
+	return false
+}
+
+func lists(ops []string, op Operation) bool {
+	for _, o := range ops {
+		if o == "*" || Operation(o) == op {
+			return true
+		}
+	}
+	return false
+}
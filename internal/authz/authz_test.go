@@ -0,0 +1,60 @@
+package authz
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/OpenDgraph/Otter/internal/auth"
+	"github.com/OpenDgraph/Otter/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthorize(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "policy.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(`
+rules:
+  - {groups: [admin], allow: ["*"], deny: [drop_all]}
+  - {subjects: [ops], allow: [drop_all]}
+`), 0600))
+
+	p, err := New(config.AuthzConfig{
+		PolicyFile: file,
+		Rules:      []config.PolicyRule{{Allow: []string{"read"}}},
+	})
+	require.NoError(t, err)
+
+	admin := &auth.Identity{Subject: "alice", Groups: []string{"admin"}}
+	require.NoError(t, p.Authorize(admin, Alter))
+	require.NoError(t, p.Authorize(admin, Read))
+	require.Error(t, p.Authorize(admin, DropAll), "a denial wins over *")
+	require.NoError(t, p.Authorize(&auth.Identity{Subject: "ops"}, DropAll))
+
+	require.NoError(t, p.Authorize(nil, Read), "rules without subjects or groups match everyone")
+	err = p.Authorize(&auth.Identity{Subject: "bob"}, Mutate)
+	var denied *Denied
+	require.ErrorAs(t, err, &denied)
+	require.Equal(t, &Denied{Operation: Mutate, Subject: "bob", Reason: "not allowed by any rule"}, denied)
+
+	out, err := json.Marshal(err)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"error":"forbidden","operation":"mutate","subject":"bob","reason":"not allowed by any rule"}`, string(out))
+
+	open, err := New(config.AuthzConfig{Default: "allow", Rules: []config.PolicyRule{{Deny: []string{"ratel"}}}})
+	require.NoError(t, err)
+	require.NoError(t, open.Authorize(nil, Mutate))
+	require.Error(t, open.Authorize(admin, Ratel))
+
+	var none *Policy
+	require.NoError(t, none.Authorize(nil, DropAll), "no policy allows everything")
+}
+
+func TestPolicyFileErrors(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "policy.yaml")
+	require.NoError(t, os.WriteFile(file, []byte("default: maybe\nrules:\n  - {allow: [delete]}\n"), 0600))
+
+	_, err := New(config.AuthzConfig{PolicyFile: file})
+	require.ErrorContains(t, err, `unknown policy "maybe"`)
+	require.ErrorContains(t, err, `rules[0]: unknown operation "delete"`)
+}
@@ -0,0 +1,136 @@
+package config
+
+import (
+	"strings"
+	"time"
+)
+
+// AuthConfig selects how clients authenticate. Every configured method is
+// tried in turn: api_keys and jwt with a token, dgraph with a user and
+// password. With nothing configured no client can authenticate.
+type AuthConfig struct {
+	APIKeys     []APIKey  `yaml:"api_keys,omitempty"`
+	APIKeysFile string    `yaml:"api_keys_file,omitempty"` // YAML list of api_keys entries
+	JWT         JWTConfig `yaml:"jwt,omitempty"`
+	Dgraph      bool      `yaml:"dgraph,omitempty"` // log in to Dgraph with the client's ACL user
+	HTTP        HTTPAuth  `yaml:"http,omitempty"`
+}
+
+// ACLConfig maps authenticated identities to Dgraph ACL users, so Dgraph
+// applies each caller's own rules. The first entry matching the identity's
+// subject or one of its groups wins. Identities that logged in with the
+// dgraph method act as themselves. Unmatched identities use dgraph_user,
+// or are refused when Unmatched is deny.
+type ACLConfig struct {
+	Users       []ACLUser     `yaml:"users,omitempty"`
+	Unmatched   string        `yaml:"unmatched,omitempty"`    // shared (default) or deny
+	IdleTimeout time.Duration `yaml:"idle_timeout,omitempty"` // default 10m
+}
+
+// ACLUser is a Dgraph user and the identities acting as it.
+type ACLUser struct {
+	Subject      string `yaml:"subject,omitempty"`
+	Group        string `yaml:"group,omitempty"`
+	User         string `yaml:"user"`
+	Password     string `yaml:"password,omitempty"`
+	PasswordFile string `yaml:"password_file,omitempty"`
+	Namespace    uint64 `yaml:"namespace,omitempty"`
+}
+
+// Unmatched policies of ACLConfig.
+const (
+	ACLShared = "shared"
+	ACLDeny   = "deny"
+)
+
+func (a ACLConfig) WithDefaults() ACLConfig {
+	if a.Unmatched == "" {
+		a.Unmatched = ACLShared
+	}
+	if a.IdleTimeout == 0 {
+		a.IdleTimeout = 10 * time.Minute
+	}
+	return a
+}
+
+// Route policies of HTTPAuth.
+const (
+	RoutePublic   = "public"
+	RouteRequired = "required"
+)
+
+// HTTPAuth says which HTTP proxy routes need authentication. Routes maps a
+// path, or a path prefix ending in "/", to a policy; the longest match wins
+// and other paths get Default. Default is required once an authentication
+// method is configured and public otherwise; /health stays public unless
+// Routes are given.
+type HTTPAuth struct {
+	Default string            `yaml:"default,omitempty"`
+	Routes  map[string]string `yaml:"routes,omitempty"`
+}
+
+// APIKey is a static key and the identity it grants.
+type APIKey struct {
+	Key     string   `yaml:"key"`
+	Subject string   `yaml:"subject"`
+	Groups  []string `yaml:"groups,omitempty"`
+	Tenant  string   `yaml:"tenant,omitempty"`
+}
+
+// JWTConfig validates bearer JWTs signed with an HMAC secret (HS256/384/512)
+// or with a key of a local JWKS file (RS256/384/512).
+type JWTConfig struct {
+	HMACSecret     string `yaml:"hmac_secret,omitempty"`
+	HMACSecretFile string `yaml:"hmac_secret_file,omitempty"`
+	JWKSFile       string `yaml:"jwks_file,omitempty"`
+	Issuer         string `yaml:"issuer,omitempty"`   // required iss when set
+	Audience       string `yaml:"audience,omitempty"` // required aud when set
+	GroupsClaim    string `yaml:"groups_claim,omitempty"`
+	TenantClaim    string `yaml:"tenant_claim,omitempty"`
+}
+
+func (j JWTConfig) Enabled() bool {
+	return j.HMACSecret != "" || j.HMACSecretFile != "" || j.JWKSFile != ""
+}
+
+func (j JWTConfig) WithDefaults() JWTConfig {
+	if j.GroupsClaim == "" {
+		j.GroupsClaim = "groups"
+	}
+	if j.TenantClaim == "" {
+		j.TenantClaim = "tenant"
+	}
+	return j
+}
+
+func (a AuthConfig) Enabled() bool {
+	return len(a.APIKeys) > 0 || a.APIKeysFile != "" || a.JWT.Enabled() || a.Dgraph
+}
+
+// RoutePolicy returns whether requests to path are public or need
+// authentication.
+func (a AuthConfig) RoutePolicy(path string) string {
+	routes := a.HTTP.Routes
+	if routes == nil {
+		routes = map[string]string{"/health": RoutePublic}
+	}
+	if policy, ok := routes[path]; ok {
+		return policy
+	}
+	best, policy := -1, ""
+	for route, p := range routes {
+		if strings.HasSuffix(route, "/") && strings.HasPrefix(path, route) && len(route) > best {
+			best, policy = len(route), p
+		}
+	}
+	if best >= 0 {
+		return policy
+	}
+	if a.HTTP.Default != "" {
+		return a.HTTP.Default
+	}
+	if a.Enabled() {
+		return RouteRequired
+	}
+	return RoutePublic
+}
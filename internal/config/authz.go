@@ -0,0 +1,82 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v2"
+)
+
+// AuthzConfig decides what callers may do once authenticated. Rules are
+// read from PolicyFile, a YAML file holding default and rules, after the
+// ones given here. Without any rule every caller may do everything.
+type AuthzConfig struct {
+	PolicyFile string       `yaml:"policy_file,omitempty"`
+	Default    string       `yaml:"default,omitempty"` // allow or deny (default)
+	Rules      []PolicyRule `yaml:"rules,omitempty"`
+}
+
+// PolicyRule allows or denies operations to the callers it matches: those
+// with one of Subjects or in one of Groups, or every caller when both are
+// empty. A denial by any matching rule wins over an allowance.
+type PolicyRule struct {
+	Subjects []string `yaml:"subjects,omitempty"`
+	Groups   []string `yaml:"groups,omitempty"`
+	Allow    []string `yaml:"allow,omitempty"` // operations, or "*" for all
+	Deny     []string `yaml:"deny,omitempty"`
+}
+
+// Default policies of AuthzConfig.
+const (
+	PolicyAllow = "allow"
+	PolicyDeny  = "deny"
+)
+
+// Operations lists what rules can allow or deny.
+var Operations = []string{"read", "mutate", "upsert", "alter", "drop_all", "ratel"}
+
+// Enabled reports whether authorization rules are configured.
+func (a AuthzConfig) Enabled() bool {
+	return len(a.Rules) > 0 || a.PolicyFile != ""
+}
+
+// LoadPolicyFile reads the default and rules of a policy file and checks
+// them as Validate checks the inline ones.
+func LoadPolicyFile(path string) (AuthzConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return AuthzConfig{}, fmt.Errorf("reading policy: %w", err)
+	}
+	var policy struct {
+		Default string       `yaml:"default,omitempty"`
+		Rules   []PolicyRule `yaml:"rules,omitempty"`
+	}
+	if err := yaml.UnmarshalStrict(data, &policy); err != nil {
+		return AuthzConfig{}, fmt.Errorf("parsing policy %s: %w", path, err)
+	}
+	a := AuthzConfig{Default: policy.Default, Rules: policy.Rules}
+
+	var errs []error
+	checkPolicy(func(at, format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("%s: %s: %s", path, at, fmt.Sprintf(format, args...)))
+	}, "", a)
+	return a, errors.Join(errs...)
+}
+
+func checkPolicy(add func(path, format string, args ...interface{}), path string, a AuthzConfig) {
+	if a.Default != "" && !oneOf(a.Default, PolicyAllow, PolicyDeny) {
+		add(path+"default", "unknown policy %q, expected allow or deny", a.Default)
+	}
+	for i, rule := range a.Rules {
+		at := fmt.Sprintf("%srules[%d]", path, i)
+		if len(rule.Allow) == 0 && len(rule.Deny) == 0 {
+			add(at, "allows and denies nothing")
+		}
+		for _, op := range append(append([]string{}, rule.Allow...), rule.Deny...) {
+			if op != "*" && !oneOf(op, Operations...) {
+				add(at, "unknown operation %q, expected one of %v", op, Operations)
+			}
+		}
+	}
+}
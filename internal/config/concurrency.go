@@ -0,0 +1,67 @@
+package config
+
+import "time"
+
+// ConcurrencyConfig caps the calls Otter has in flight to each alpha, and to
+// each purpose group of the defined balancer, so a burst waits in Otter
+// instead of piling onto Dgraph. Calls over a cap queue, up to QueueSize per
+// cap, for at most QueueTimeout, by the priority named in PriorityHeader.
+type ConcurrencyConfig struct {
+	MaxPerEndpoint int            `yaml:"max_per_endpoint,omitempty"` // 0: no cap
+	Groups         map[string]int `yaml:"groups,omitempty"`           // purpose group -> cap
+	QueueSize      int            `yaml:"queue_size,omitempty"`
+	QueueTimeout   time.Duration  `yaml:"queue_timeout,omitempty"`
+	PriorityHeader string         `yaml:"priority_header,omitempty"`
+	Adaptive       AdaptiveConfig `yaml:"adaptive,omitempty"`
+}
+
+// WithDefaults returns a copy of c with every unset field filled in.
+func (c ConcurrencyConfig) WithDefaults() ConcurrencyConfig {
+	if c.QueueSize <= 0 {
+		c.QueueSize = 100
+	}
+	if c.QueueTimeout <= 0 {
+		c.QueueTimeout = 5 * time.Second
+	}
+	if c.PriorityHeader == "" {
+		c.PriorityHeader = "X-Otter-Priority"
+	}
+	return c
+}
+
+// AdaptiveConfig lets the cap of each alpha follow its health (AIMD): it
+// grows by one for every cap's worth of calls answered within Latency and
+// shrinks by Backoff when a call fails or is slower. It stays between
+// MinLimit and max_per_endpoint, 1000 when that is unset. Batch calls may
+// take BatchShare of the cap and are refused beyond it.
+type AdaptiveConfig struct {
+	Enabled      bool          `yaml:"enabled"`
+	MinLimit     int           `yaml:"min_limit,omitempty"`
+	InitialLimit int           `yaml:"initial_limit,omitempty"`
+	Latency      time.Duration `yaml:"latency,omitempty"`
+	Backoff      float64       `yaml:"backoff,omitempty"`
+	BatchShare   float64       `yaml:"batch_share,omitempty"`
+}
+
+// DefaultMaxAdaptiveLimit bounds an adaptive cap without max_per_endpoint.
+const DefaultMaxAdaptiveLimit = 1000
+
+// WithDefaults returns a copy of a with every unset field filled in.
+func (a AdaptiveConfig) WithDefaults() AdaptiveConfig {
+	if a.MinLimit <= 0 {
+		a.MinLimit = 1
+	}
+	if a.InitialLimit <= 0 {
+		a.InitialLimit = 20
+	}
+	if a.Latency <= 0 {
+		a.Latency = time.Second
+	}
+	if a.Backoff <= 0 {
+		a.Backoff = 0.9
+	}
+	if a.BatchShare <= 0 {
+		a.BatchShare = 0.5
+	}
+	return a
+}
@@ -6,23 +6,248 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"gopkg.in/yaml.v2"
 )
 
 type Config struct {
-	Groups          map[string][]string `yaml:"groups,omitempty"` // query, mutation, upsert
-	DgraphEndpoints []string            `yaml:"dgraph_endpoints"`
-	BalancerType    string              `yaml:"balancer_type"`
-	ProxyPort       int                 `yaml:"proxy_port"`
-	WebSocketPort   int                 `yaml:"websocket_port"`
-	DgraphUser      string              `yaml:"dgraph_user"`
-	DgraphPassword  string              `yaml:"dgraph_password"`
-	EnableHTTP      *bool               `yaml:"enable_http"`
-	GraphQL         *bool               `yaml:"graphql"`
-	EnableWebSocket *bool               `yaml:"enable_websocket"`
-	Ratel           string              `yaml:"ratel"`
-	RatelGraphQL    *bool               `yaml:"ratel_graphql"`
+	Groups          map[string][]Endpoint `yaml:"groups,omitempty"` // query, mutation, upsert
+	GroupBalancer   string                `yaml:"group_balancer_type,omitempty"`
+	Fallbacks       map[string][]string   `yaml:"fallbacks,omitempty"` // purpose -> groups tried in order
+	DgraphEndpoints []Endpoint            `yaml:"dgraph_endpoints"`
+	BalancerType    string                `yaml:"balancer_type"`
+	ProxyPort       int                   `yaml:"proxy_port"`
+	WebSocketPort   int                   `yaml:"websocket_port"`
+	DgraphUser      string                `yaml:"dgraph_user"`
+	DgraphPassword  string                `yaml:"dgraph_password"`
+	EnableHTTP      *bool                 `yaml:"enable_http"`
+	GraphQL         *bool                 `yaml:"graphql"`
+	EnableWebSocket *bool                 `yaml:"enable_websocket"`
+	Ratel           string                `yaml:"ratel"`
+	RatelGraphQL    *bool                 `yaml:"ratel_graphql"`
+	HealthCheck     HealthCheckConfig     `yaml:"health_check,omitempty"`
+	Outlier         OutlierConfig         `yaml:"outlier_detection,omitempty"`
+	EWMA            EWMAConfig            `yaml:"ewma,omitempty"`
+	ConsistentHash  ConsistentHashConfig  `yaml:"consistent_hash,omitempty"`
+	Classify        ClassifyConfig        `yaml:"classify,omitempty"`
+	Discovery       DiscoveryConfig       `yaml:"discovery,omitempty"`
+	ShutdownTimeout time.Duration         `yaml:"shutdown_timeout,omitempty"` // default 30s
+	TLS             ServerTLS             `yaml:"tls,omitempty"`              // proxy and WebSocket listeners
+	DgraphTLS       ClientTLS             `yaml:"dgraph_tls,omitempty"`       // default for endpoints without tls
+	Auth            AuthConfig            `yaml:"auth,omitempty"`
+	DgraphACL       ACLConfig             `yaml:"dgraph_acl,omitempty"`
+	Tenancy         TenancyConfig         `yaml:"tenancy,omitempty"`
+	Authz           AuthzConfig           `yaml:"authz,omitempty"`
+	RateLimits      []RateLimit           `yaml:"rate_limits,omitempty"` // every one must admit a call
+	Concurrency     ConcurrencyConfig     `yaml:"concurrency,omitempty"`
+	Timeouts        TimeoutConfig         `yaml:"timeouts,omitempty"`
+	Retries         RetryConfig           `yaml:"retries,omitempty"`
+}
+
+// DiscoveryConfig makes Otter poll the /state endpoint of a Dgraph Zero
+// (host:6080) for the alphas of the cluster instead of relying only on
+// dgraph_endpoints. Zeros are tried in order. Purposes maps a purpose to one
+// of the discovered groups: "leaders", "followers" or "group-<id>". TLS
+// configures the connections to the Zeros, which stay in plaintext unless
+// it is enabled.
+type DiscoveryConfig struct {
+	Zeros    []string          `yaml:"zeros,omitempty"`
+	Interval time.Duration     `yaml:"interval,omitempty"`
+	Timeout  time.Duration     `yaml:"timeout,omitempty"`
+	Purposes map[string]string `yaml:"purposes,omitempty"`
+	TLS      ClientTLS         `yaml:"tls,omitempty"`
+}
+
+// Enabled reports whether at least one Zero is configured.
+func (d DiscoveryConfig) Enabled() bool {
+	return len(d.Zeros) > 0
+}
+
+// HTTPScheme returns the scheme used to reach the Zeros.
+func (d DiscoveryConfig) HTTPScheme() string {
+	if d.TLS.Enabled {
+		return "https"
+	}
+	return "http"
+}
+
+// WithDefaults returns a copy of d with every unset field filled in.
+func (d DiscoveryConfig) WithDefaults() DiscoveryConfig {
+	if d.Interval <= 0 {
+		d.Interval = 10 * time.Second
+	}
+	if d.Timeout <= 0 {
+		d.Timeout = 3 * time.Second
+	}
+	return d
+}
+
+// ClassifyConfig enables finer purposes for defined balancing. Requests are
+// parsed and sent to the group of their kind (schema, traversal, aggregation,
+// bulk-mutation, delete, upsert) when one is configured. A mutation counts as
+// bulk from BulkThreshold N-Quads or JSON objects.
+type ClassifyConfig struct {
+	Enabled       bool `yaml:"enabled"`
+	BulkThreshold int  `yaml:"bulk_threshold,omitempty"`
+}
+
+// WithDefaults returns a copy of c with every unset field filled in.
+func (c ClassifyConfig) WithDefaults() ClassifyConfig {
+	if c.BulkThreshold <= 0 {
+		c.BulkThreshold = 1000
+	}
+	return c
+}
+
+// ConsistentHashConfig tunes the consistent-hash balancer. Header names the
+// request header a client can use to pick its own routing key.
+type ConsistentHashConfig struct {
+	Replicas int    `yaml:"replicas,omitempty"`
+	Header   string `yaml:"header,omitempty"`
+}
+
+// WithDefaults returns a copy of c with every unset field filled in.
+func (c ConsistentHashConfig) WithDefaults() ConsistentHashConfig {
+	if c.Replicas <= 0 {
+		c.Replicas = 160
+	}
+	if c.Header == "" {
+		c.Header = "X-Otter-Route-Key"
+	}
+	return c
+}
+
+// EWMAConfig tunes the latency-aware balancers. Decay is the time constant of
+// the moving average: older samples lose weight as e^(-elapsed/decay).
+type EWMAConfig struct {
+	Decay          time.Duration `yaml:"decay,omitempty"`
+	FailurePenalty time.Duration `yaml:"failure_penalty,omitempty"`
+}
+
+// WithDefaults returns a copy of e with every unset field filled in.
+func (e EWMAConfig) WithDefaults() EWMAConfig {
+	if e.Decay <= 0 {
+		e.Decay = 10 * time.Second
+	}
+	if e.FailurePenalty <= 0 {
+		e.FailurePenalty = time.Second
+	}
+	return e
+}
+
+// HealthCheckConfig controls the active probing of Dgraph alphas.
+// It is always on for the round-robin-healthy balancer and opt-in
+// (Enabled) for the purposeful/defined balancer.
+type HealthCheckConfig struct {
+	Enabled          bool          `yaml:"enabled,omitempty"`
+	Mode             string        `yaml:"mode,omitempty"` // http, grpc or both
+	Path             string        `yaml:"path,omitempty"`
+	Interval         time.Duration `yaml:"interval,omitempty"`
+	Timeout          time.Duration `yaml:"timeout,omitempty"`
+	FailureThreshold int           `yaml:"failure_threshold,omitempty"`
+	SuccessThreshold int           `yaml:"success_threshold,omitempty"`
+}
+
+// OutlierConfig controls passive ejection of endpoints based on the outcome
+// of real Dgraph calls.
+type OutlierConfig struct {
+	Enabled           bool          `yaml:"enabled,omitempty"`
+	ConsecutiveErrors int           `yaml:"consecutive_errors,omitempty"`
+	BaseEjection      time.Duration `yaml:"base_ejection,omitempty"`
+	MaxEjection       time.Duration `yaml:"max_ejection,omitempty"`
+}
+
+// WithDefaults returns a copy of o with every unset field filled in.
+func (o OutlierConfig) WithDefaults() OutlierConfig {
+	if o.ConsecutiveErrors <= 0 {
+		o.ConsecutiveErrors = 5
+	}
+	if o.BaseEjection <= 0 {
+		o.BaseEjection = 30 * time.Second
+	}
+	if o.MaxEjection <= 0 {
+		o.MaxEjection = 5 * time.Minute
+	}
+	if o.MaxEjection < o.BaseEjection {
+		o.MaxEjection = o.BaseEjection
+	}
+	return o
+}
+
+// WithDefaults returns a copy of h with every unset field filled in.
+func (h HealthCheckConfig) WithDefaults() HealthCheckConfig {
+	if h.Mode == "" {
+		h.Mode = "http"
+	}
+	if h.Path == "" {
+		h.Path = "/health"
+	}
+	if h.Interval <= 0 {
+		h.Interval = 5 * time.Second
+	}
+	if h.Timeout <= 0 {
+		h.Timeout = 2 * time.Second
+	}
+	if h.FailureThreshold <= 0 {
+		h.FailureThreshold = 3
+	}
+	if h.SuccessThreshold <= 0 {
+		h.SuccessThreshold = 2
+	}
+	return h
+}
+
+// Redacted returns a copy of c safe to print, without the Dgraph password
+// and the authentication secrets.
+func (c Config) Redacted() Config {
+	if c.DgraphPassword != "" {
+		c.DgraphPassword = "<redacted>"
+	}
+	if len(c.Auth.APIKeys) > 0 {
+		keys := make([]APIKey, len(c.Auth.APIKeys))
+		for i, key := range c.Auth.APIKeys {
+			key.Key = "<redacted>"
+			keys[i] = key
+		}
+		c.Auth.APIKeys = keys
+	}
+	if c.Auth.JWT.HMACSecret != "" {
+		c.Auth.JWT.HMACSecret = "<redacted>"
+	}
+	if len(c.DgraphACL.Users) > 0 {
+		users := make([]ACLUser, len(c.DgraphACL.Users))
+		for i, user := range c.DgraphACL.Users {
+			if user.Password != "" {
+				user.Password = "<redacted>"
+			}
+			users[i] = user
+		}
+		c.DgraphACL.Users = users
+	}
+	if len(c.Tenancy.Tenants) > 0 {
+		tenants := make(map[string]Tenant, len(c.Tenancy.Tenants))
+		for name, tenant := range c.Tenancy.Tenants {
+			if tenant.Password != "" {
+				tenant.Password = "<redacted>"
+			}
+			tenants[name] = tenant
+		}
+		c.Tenancy.Tenants = tenants
+	}
+	return c
+}
+
+// HTTPEnabled reports whether the HTTP proxy listener runs. Unset means
+// enabled, as in LoadConfig.
+func (c Config) HTTPEnabled() bool {
+	return c.EnableHTTP == nil || *c.EnableHTTP
+}
+
+// WebSocketEnabled reports whether the WebSocket listener runs. Unset means
+// enabled, as in LoadConfig.
+func (c Config) WebSocketEnabled() bool {
+	return c.EnableWebSocket == nil || *c.EnableWebSocket
 }
 
 func LoadConfig() (*Config, error) {
@@ -34,7 +259,7 @@ func LoadConfig() (*Config, error) {
 		if err != nil {
 			return nil, fmt.Errorf("failed to read specified config file %q: %w", filePath, err)
 		}
-		if err := yaml.Unmarshal(data, &cfg); err != nil {
+		if err := yaml.UnmarshalStrict(data, &cfg); err != nil {
 			return nil, fmt.Errorf("failed to parse YAML config from %q: %w", filePath, err)
 		}
 		log.Printf("Successfully loaded config from %s", filePath)
@@ -57,11 +282,9 @@ func LoadConfig() (*Config, error) {
 		if val := os.Getenv("RATEL_GRAPHQL"); val != "" {
 			parsedVal, err := strconv.ParseBool(val)
 			if err != nil {
-				cfg.RatelGraphQL = ptrBool(strings.ToLower(val) != "false")
-				log.Printf("Warning: Invalid boolean value for RATEL_GRAPHQL env var: %q. Using simple check.", val)
-			} else {
-				cfg.RatelGraphQL = &parsedVal
+				return nil, fmt.Errorf("invalid RATEL_GRAPHQL environment variable %q: expected true or false", val)
 			}
+			cfg.RatelGraphQL = &parsedVal
 			log.Printf("RatelGraphQL set from environment: %v", *cfg.RatelGraphQL)
 		} else {
 			defaultVal := true
@@ -76,11 +299,9 @@ func LoadConfig() (*Config, error) {
 		if val := os.Getenv("ENABLE_HTTP"); val != "" {
 			parsedVal, err := strconv.ParseBool(val)
 			if err != nil {
-				cfg.EnableHTTP = ptrBool(strings.ToLower(val) != "false")
-				log.Printf("Warning: Invalid boolean value for ENABLE_HTTP env var: %q. Using simple check.", val)
-			} else {
-				cfg.EnableHTTP = &parsedVal
+				return nil, fmt.Errorf("invalid ENABLE_HTTP environment variable %q: expected true or false", val)
 			}
+			cfg.EnableHTTP = &parsedVal
 			log.Printf("EnableHTTP set from environment: %v", *cfg.EnableHTTP)
 		} else {
 			defaultVal := true
@@ -95,11 +316,9 @@ func LoadConfig() (*Config, error) {
 		if val := os.Getenv("GRAPHQL"); val != "" {
 			parsedVal, err := strconv.ParseBool(val)
 			if err != nil {
-				cfg.GraphQL = ptrBool(strings.ToLower(val) != "false")
-				log.Printf("Warning: Invalid boolean value for GRAPHQL env var: %q. Using simple check.", val)
-			} else {
-				cfg.GraphQL = &parsedVal
+				return nil, fmt.Errorf("invalid GRAPHQL environment variable %q: expected true or false", val)
 			}
+			cfg.GraphQL = &parsedVal
 			log.Printf("GraphQL set from environment: %v", *cfg.GraphQL)
 		} else {
 			defaultVal := true
@@ -114,11 +333,9 @@ func LoadConfig() (*Config, error) {
 		if val := os.Getenv("ENABLE_WEBSOCKET"); val != "" {
 			parsedVal, err := strconv.ParseBool(val)
 			if err != nil {
-				cfg.EnableWebSocket = ptrBool(strings.ToLower(val) != "false")
-				log.Printf("Warning: Invalid boolean value for ENABLE_WEBSOCKET env var: %q. Using simple check.", val)
-			} else {
-				cfg.EnableWebSocket = &parsedVal
+				return nil, fmt.Errorf("invalid ENABLE_WEBSOCKET environment variable %q: expected true or false", val)
 			}
+			cfg.EnableWebSocket = &parsedVal
 			log.Printf("EnableWebSocket set from environment: %v", *cfg.EnableWebSocket)
 		} else {
 			defaultVal := true
@@ -156,10 +373,14 @@ func LoadConfig() (*Config, error) {
 	}
 
 	if val := os.Getenv("DGRAPH_ENDPOINTS"); val != "" {
-		endpoints := []string{}
+		endpoints := []Endpoint{}
 		for _, ep := range strings.Split(val, ",") {
 			if trimmed := strings.TrimSpace(ep); trimmed != "" {
-				endpoints = append(endpoints, trimmed)
+				endpoint, err := ParseEndpoint(trimmed)
+				if err != nil {
+					return nil, fmt.Errorf("invalid DGRAPH_ENDPOINTS environment variable: %w", err)
+				}
+				endpoints = append(endpoints, endpoint)
 			}
 		}
 		if len(endpoints) > 0 {
@@ -183,9 +404,21 @@ func LoadConfig() (*Config, error) {
 			log.Printf("DGRAPH_ENDPOINTS not set in env or YAML.")
 		}
 	}
-	if len(cfg.DgraphEndpoints) == 0 {
+	if val := os.Getenv("DGRAPH_ZEROS"); val != "" {
+		var zeros []string
+		for _, zero := range strings.Split(val, ",") {
+			if trimmed := strings.TrimSpace(zero); trimmed != "" {
+				zeros = append(zeros, trimmed)
+			}
+		}
+		cfg.Discovery.Zeros = zeros
+		log.Printf("DGRAPH_ZEROS set from environment: %v", zeros)
+	}
+	if len(cfg.DgraphEndpoints) == 0 && cfg.Discovery.Enabled() {
+		log.Printf("DgraphEndpoints is empty. Alphas will be discovered from Zero %v.", cfg.Discovery.Zeros)
+	} else if len(cfg.DgraphEndpoints) == 0 {
 		log.Printf("Error: DgraphEndpoints is empty after checking YAML and ENV.")
-		return nil, fmt.Errorf("DGRAPH_ENDPOINTS must be set either via env or YAML and contain valid endpoints")
+		return nil, fmt.Errorf("DGRAPH_ENDPOINTS must be set either via env or YAML and contain valid endpoints, or DGRAPH_ZEROS for discovery")
 	}
 
 	defaultBalancer := "round-robin"
@@ -264,7 +497,12 @@ func LoadConfig() (*Config, error) {
 		cfg.WebSocketPort = defaultWebSocketPort
 	}
 
-	if cfgYaml, err := yaml.Marshal(&cfg); err == nil {
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration:\n%w", err)
+	}
+
+	redacted := cfg.Redacted()
+	if cfgYaml, err := yaml.Marshal(&redacted); err == nil {
 		log.Println("--- Final Loaded Configuration ---")
 		for _, line := range strings.Split(strings.TrimSpace(string(cfgYaml)), "\n") {
 			log.Println("  " + line)
@@ -277,7 +515,3 @@ func LoadConfig() (*Config, error) {
 
 	return &cfg, nil
 }
-
-func ptrBool(b bool) *bool {
-	return &b
-}
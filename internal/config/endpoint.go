@@ -0,0 +1,134 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Endpoint is a Dgraph alpha as listed in dgraph_endpoints or in a purpose
+// group. In YAML it is either a plain gRPC "host:port" string or a map:
+//
+//   - localhost:9080
+//   - {addr: localhost:9081, weight: 3}
+//   - {grpc: alpha-0.dgraph:443, http: alpha-0-http.dgraph:443, tls: true}
+//   - {addr: alpha-1.dgraph:9080, tls: {ca_file: ca.crt, cert_file: otter.crt, key_file: otter.key}}
+//
+// grpc is accepted as a synonym of addr. Without http, the HTTP address is
+// derived with Dgraph's default layout: the gRPC port minus 1000. See
+// ClientTLS for tls.
+type Endpoint struct {
+	Addr   string    `yaml:"addr"`
+	HTTP   string    `yaml:"http,omitempty"`
+	TLS    ClientTLS `yaml:"tls,omitempty"`
+	Weight int       `yaml:"weight,omitempty"`
+}
+
+// GetWeight returns the weight of e, defaulting to 1.
+func (e Endpoint) GetWeight() int {
+	if e.Weight <= 0 {
+		return 1
+	}
+	return e.Weight
+}
+
+func (e Endpoint) String() string {
+	if e.Weight > 1 {
+		return fmt.Sprintf("%s=%d", e.Addr, e.Weight)
+	}
+	return e.Addr
+}
+
+func (e *Endpoint) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var addr string
+	if err := unmarshal(&addr); err == nil {
+		*e = Endpoint{Addr: addr}
+		return nil
+	}
+
+	var p struct {
+		Addr   string    `yaml:"addr"`
+		GRPC   string    `yaml:"grpc"`
+		HTTP   string    `yaml:"http"`
+		TLS    ClientTLS `yaml:"tls"`
+		Weight int       `yaml:"weight"`
+	}
+	if err := unmarshal(&p); err != nil {
+		return fmt.Errorf("endpoint must be \"host:port\" or {addr|grpc, http, tls, weight}: %w", err)
+	}
+	if p.Addr != "" && p.GRPC != "" && p.Addr != p.GRPC {
+		return fmt.Errorf("endpoint sets both addr %q and grpc %q", p.Addr, p.GRPC)
+	}
+	if p.Addr == "" {
+		p.Addr = p.GRPC
+	}
+	*e = Endpoint{Addr: p.Addr, HTTP: p.HTTP, TLS: p.TLS, Weight: p.Weight}
+	return nil
+}
+
+func (e Endpoint) MarshalYAML() (interface{}, error) {
+	if e.Weight <= 1 && e.HTTP == "" && e.TLS == (ClientTLS{}) {
+		return e.Addr, nil
+	}
+	type plain Endpoint
+	return plain(e), nil
+}
+
+// HTTPAddr returns the address of the alpha's HTTP listener: the configured
+// one or, when none is set, Dgraph's default pairing of the two listeners,
+// HTTP 1000 below gRPC (8080 and 9080, shifted together by --port_offset).
+func (e Endpoint) HTTPAddr() (string, error) {
+	if e.HTTP != "" {
+		return e.HTTP, nil
+	}
+	host, portStr, err := net.SplitHostPort(e.Addr)
+	if err != nil {
+		return "", fmt.Errorf("invalid endpoint format '%s': %w", e.Addr, err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return "", fmt.Errorf("invalid port in endpoint '%s': %w", e.Addr, err)
+	}
+	return net.JoinHostPort(host, strconv.Itoa(port-1000)), nil
+}
+
+// HTTPScheme returns the scheme used to reach the alpha's HTTP listener.
+func (e Endpoint) HTTPScheme() string {
+	if e.TLS.Enabled {
+		return "https"
+	}
+	return "http"
+}
+
+// ParseEndpoint parses the "host:port" or "host:port=weight" form used by
+// the DGRAPH_ENDPOINTS environment variable.
+func ParseEndpoint(s string) (Endpoint, error) {
+	addr, weightStr, found := strings.Cut(strings.TrimSpace(s), "=")
+	if !found {
+		return Endpoint{Addr: addr}, nil
+	}
+	weight, err := strconv.Atoi(weightStr)
+	if err != nil || weight <= 0 {
+		return Endpoint{}, fmt.Errorf("invalid weight %q for endpoint %q", weightStr, addr)
+	}
+	return Endpoint{Addr: addr, Weight: weight}, nil
+}
+
+// Addrs returns the addresses of endpoints, in order.
+func Addrs(endpoints []Endpoint) []string {
+	addrs := make([]string, 0, len(endpoints))
+	for _, ep := range endpoints {
+		addrs = append(addrs, ep.Addr)
+	}
+	return addrs
+}
+
+// EndpointsFromAddrs wraps plain addresses as endpoints of weight 1.
+func EndpointsFromAddrs(addrs ...string) []Endpoint {
+	endpoints := make([]Endpoint, 0, len(addrs))
+	for _, addr := range addrs {
+		endpoints = append(endpoints, Endpoint{Addr: addr})
+	}
+	return endpoints
+}
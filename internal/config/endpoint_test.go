@@ -0,0 +1,88 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestEndpointYAML(t *testing.T) {
+	src := `
+dgraph_endpoints:
+  - localhost:9080
+  - {addr: localhost:9081, weight: 3}
+groups:
+  query:
+    - addr: localhost:9082
+      weight: 2
+    - localhost:9083
+`
+	var cfg Config
+	require.NoError(t, yaml.Unmarshal([]byte(src), &cfg))
+	require.Equal(t, []Endpoint{{Addr: "localhost:9080"}, {Addr: "localhost:9081", Weight: 3}}, cfg.DgraphEndpoints)
+	require.Equal(t, []Endpoint{{Addr: "localhost:9082", Weight: 2}, {Addr: "localhost:9083"}}, cfg.Groups["query"])
+	require.Equal(t, 1, cfg.DgraphEndpoints[0].GetWeight())
+
+	out, err := yaml.Marshal(cfg.DgraphEndpoints)
+	require.NoError(t, err)
+	require.Equal(t, "- localhost:9080\n- addr: localhost:9081\n  weight: 3\n", string(out))
+}
+
+func TestParseEndpoint(t *testing.T) {
+	ep, err := ParseEndpoint(" localhost:9080=4 ")
+	require.NoError(t, err)
+	require.Equal(t, Endpoint{Addr: "localhost:9080", Weight: 4}, ep)
+
+	ep, err = ParseEndpoint("localhost:9080")
+	require.NoError(t, err)
+	require.Equal(t, Endpoint{Addr: "localhost:9080"}, ep)
+
+	_, err = ParseEndpoint("localhost:9080=zero")
+	require.Error(t, err)
+}
+
+func TestEndpointAddressPairs(t *testing.T) {
+	src := `
+- {grpc: alpha-0.dgraph:443, http: alpha-0-http.dgraph:443, tls: true}
+- localhost:9081
+`
+	var eps []Endpoint
+	require.NoError(t, yaml.Unmarshal([]byte(src), &eps))
+	require.Equal(t, Endpoint{Addr: "alpha-0.dgraph:443", HTTP: "alpha-0-http.dgraph:443", TLS: ClientTLS{Enabled: true}}, eps[0])
+
+	addr, err := eps[0].HTTPAddr()
+	require.NoError(t, err)
+	require.Equal(t, "alpha-0-http.dgraph:443", addr)
+	require.Equal(t, "https", eps[0].HTTPScheme())
+
+	addr, err = eps[1].HTTPAddr()
+	require.NoError(t, err)
+	require.Equal(t, "localhost:8081", addr, "HTTP address defaults to the gRPC port minus 1000")
+	require.Equal(t, "http", eps[1].HTTPScheme())
+
+	require.Error(t, yaml.Unmarshal([]byte(`[{addr: a:9080, grpc: b:9080}]`), &eps))
+}
+
+func TestEndpointTLS(t *testing.T) {
+	src := `
+dgraph_tls: {ca_file: ca.crt}
+dgraph_endpoints:
+  - {addr: alpha-0:9080, tls: {ca_file: other.crt, cert_file: otter.crt, key_file: otter.key}}
+  - {addr: alpha-1:9080, tls: false}
+  - alpha-2:9080
+`
+	var cfg Config
+	require.NoError(t, yaml.UnmarshalStrict([]byte(src), &cfg))
+	require.Equal(t, ClientTLS{Enabled: true, CAFile: "ca.crt"}, cfg.DgraphTLS, "a map implies enabled")
+
+	eps := cfg.WithEndpointTLS().DgraphEndpoints
+	require.Equal(t, ClientTLS{Enabled: true, CAFile: "other.crt", CertFile: "otter.crt", KeyFile: "otter.key"}, eps[0].TLS)
+	require.Equal(t, cfg.DgraphTLS, eps[1].TLS, "tls: false is the same as unset")
+	require.Equal(t, cfg.DgraphTLS, eps[2].TLS)
+	require.Equal(t, ClientTLS{}, cfg.DgraphEndpoints[2].TLS, "the config itself is not modified")
+
+	out, err := yaml.Marshal(Endpoint{Addr: "alpha-0:9080", TLS: ClientTLS{Enabled: true}})
+	require.NoError(t, err)
+	require.Equal(t, "addr: alpha-0:9080\ntls: true\n", string(out))
+}
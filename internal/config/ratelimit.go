@@ -0,0 +1,56 @@
+package config
+
+// RateLimit is a set of token buckets, one per caller as told apart by By,
+// with separate budgets for queries, mutations and upserts. Schema changes
+// count as mutations. Overrides replaces the budgets of some callers, such
+// as a large tenant.
+type RateLimit struct {
+	By        string                 `yaml:"by"` // subject, tenant, ip or session
+	Query     Bucket                 `yaml:"query,omitempty"`
+	Mutation  Bucket                 `yaml:"mutation,omitempty"`
+	Upsert    Bucket                 `yaml:"upsert,omitempty"`
+	Overrides map[string]RateBudgets `yaml:"overrides,omitempty"`
+}
+
+// RateBudgets are the buckets of one caller.
+type RateBudgets struct {
+	Query    Bucket `yaml:"query,omitempty"`
+	Mutation Bucket `yaml:"mutation,omitempty"`
+	Upsert   Bucket `yaml:"upsert,omitempty"`
+}
+
+// Bucket refills Rate tokens per second up to Burst; every call takes one.
+// A zero Rate does not limit.
+type Bucket struct {
+	Rate  float64 `yaml:"rate"`
+	Burst int     `yaml:"burst,omitempty"` // default max(1, rate)
+}
+
+// Ways of telling callers apart in RateLimit.By.
+const (
+	RateBySubject = "subject" // the authenticated identity, such as an API key
+	RateByTenant  = "tenant"
+	RateByIP      = "ip"
+	RateBySession = "session" // a WebSocket session
+)
+
+// RateKeys lists the values accepted for RateLimit.By.
+var RateKeys = []string{RateBySubject, RateByTenant, RateByIP, RateBySession}
+
+// Budgets returns the buckets of the caller key.
+func (r RateLimit) Budgets(key string) RateBudgets {
+	if budgets, ok := r.Overrides[key]; ok {
+		return budgets
+	}
+	return RateBudgets{Query: r.Query, Mutation: r.Mutation, Upsert: r.Upsert}
+}
+
+func (b Bucket) WithDefaults() Bucket {
+	if b.Burst <= 0 {
+		b.Burst = int(b.Rate)
+		if b.Burst < 1 {
+			b.Burst = 1
+		}
+	}
+	return b
+}
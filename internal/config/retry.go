@@ -0,0 +1,52 @@
+package config
+
+import "time"
+
+// RetryConfig makes Otter run failed calls again. A call that failed with
+// the alpha unavailable or the connection reset is retried on another
+// alpha, up to Attempts times, when it is safe: reads, writes that never
+// reached the alpha, and writes the client named with a key in
+// IdempotencyHeader. Otter runs a keyed write once per caller and key: a
+// repeat while it runs, or within IdempotencyTTL after it succeeded, gets
+// its result. Retries take from a budget earning Budget retries per call,
+// holding at most BudgetBurst, so they cannot multiply the load of a
+// failing cluster. Upserts aborted by a conflicting transaction are run
+// again up to AbortedUpserts times.
+type RetryConfig struct {
+	Attempts       int           `yaml:"attempts,omitempty"` // 0: no retry
+	Budget         float64       `yaml:"budget,omitempty"`
+	BudgetBurst    int           `yaml:"budget_burst,omitempty"`
+	Backoff        time.Duration `yaml:"backoff,omitempty"` // doubled per attempt, with jitter
+	MaxBackoff     time.Duration `yaml:"max_backoff,omitempty"`
+	AbortedUpserts int           `yaml:"aborted_upserts,omitempty"`
+
+	IdempotencyHeader string        `yaml:"idempotency_header,omitempty"`
+	IdempotencyTTL    time.Duration `yaml:"idempotency_ttl,omitempty"`
+	IdempotencyKeys   int           `yaml:"idempotency_keys,omitempty"` // results kept at most
+}
+
+// WithDefaults returns a copy of r with every unset field filled in.
+func (r RetryConfig) WithDefaults() RetryConfig {
+	if r.Budget <= 0 {
+		r.Budget = 0.2
+	}
+	if r.BudgetBurst <= 0 {
+		r.BudgetBurst = 10
+	}
+	if r.Backoff <= 0 {
+		r.Backoff = 25 * time.Millisecond
+	}
+	if r.MaxBackoff <= 0 {
+		r.MaxBackoff = time.Second
+	}
+	if r.IdempotencyHeader == "" {
+		r.IdempotencyHeader = "X-Otter-Idempotency-Key"
+	}
+	if r.IdempotencyTTL <= 0 {
+		r.IdempotencyTTL = 5 * time.Minute
+	}
+	if r.IdempotencyKeys <= 0 {
+		r.IdempotencyKeys = 10000
+	}
+	return r
+}
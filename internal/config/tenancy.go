@@ -0,0 +1,40 @@
+package config
+
+// TenancyConfig maps tenants to Dgraph namespaces. A caller's tenant comes
+// from its credentials: the tenant of an API key, the tenant claim of a JWT
+// or the namespace of a dgraph login. Callers whose credentials name no
+// tenant may pick one they are a member of with Header, or with the tenant
+// field of the WebSocket auth message; without any auth method configured
+// every caller may pick any tenant.
+type TenancyConfig struct {
+	Header  string            `yaml:"header,omitempty"` // default X-Otter-Tenant
+	Tenants map[string]Tenant `yaml:"tenants,omitempty"`
+}
+
+// Tenant is a Dgraph namespace and what its callers may do there. Calls are
+// made as User in the namespace, dgraph_user when User is empty.
+type Tenant struct {
+	Namespace     uint64   `yaml:"namespace"`
+	User          string   `yaml:"user,omitempty"`
+	Password      string   `yaml:"password,omitempty"`
+	PasswordFile  string   `yaml:"password_file,omitempty"`
+	Members       []string `yaml:"members,omitempty"`        // subjects and groups that may pick the tenant
+	Purposes      []string `yaml:"purposes,omitempty"`       // purposes allowed, all when empty
+	Groups        []string `yaml:"groups,omitempty"`         // endpoint groups serving the tenant, tried in order
+	MaxConcurrent int      `yaml:"max_concurrent,omitempty"` // requests running at once, 0 for no limit
+}
+
+// DefaultTenantHeader is the request header naming the tenant.
+const DefaultTenantHeader = "X-Otter-Tenant"
+
+// Enabled reports whether at least one tenant is configured.
+func (t TenancyConfig) Enabled() bool {
+	return len(t.Tenants) > 0
+}
+
+func (t TenancyConfig) WithDefaults() TenancyConfig {
+	if t.Header == "" {
+		t.Header = DefaultTenantHeader
+	}
+	return t
+}
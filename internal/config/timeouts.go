@@ -0,0 +1,47 @@
+package config
+
+import "time"
+
+// TimeoutConfig bounds how long Otter works on a call. A call gets the
+// timeout of its purpose, else the one of its HTTP route, else Default.
+// Clients may ask for their own in Header or the timeout field of a
+// WebSocket message; it replaces the configured one but cannot exceed Max,
+// or the configured one when Max is unset.
+type TimeoutConfig struct {
+	Default  time.Duration            `yaml:"default,omitempty"`  // 0: none
+	Routes   map[string]time.Duration `yaml:"routes,omitempty"`   // path, such as /query -> timeout
+	Purposes map[string]time.Duration `yaml:"purposes,omitempty"` // purpose -> timeout
+	Max      time.Duration            `yaml:"max,omitempty"`
+	Header   string                   `yaml:"header,omitempty"`
+}
+
+// WithDefaults returns a copy of t with every unset field filled in.
+func (t TimeoutConfig) WithDefaults() TimeoutConfig {
+	if t.Header == "" {
+		t.Header = "X-Otter-Timeout"
+	}
+	return t
+}
+
+// Timeout returns how long a call for purpose on route may take when the
+// client asked for requested, 0 when it did not. A zero result means no
+// timeout.
+func (t TimeoutConfig) Timeout(route, purpose string, requested time.Duration) time.Duration {
+	configured, ok := t.Purposes[purpose]
+	if !ok {
+		if configured, ok = t.Routes[route]; !ok {
+			configured = t.Default
+		}
+	}
+	if requested <= 0 {
+		return configured
+	}
+	ceiling := t.Max
+	if ceiling <= 0 {
+		ceiling = configured
+	}
+	if ceiling > 0 && requested > ceiling {
+		return ceiling
+	}
+	return requested
+}
@@ -0,0 +1,27 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimeout(t *testing.T) {
+	cfg := TimeoutConfig{
+		Default:  10 * time.Second,
+		Routes:   map[string]time.Duration{"/mutate": 30 * time.Second},
+		Purposes: map[string]time.Duration{"bulk-mutation": time.Minute},
+	}
+	require.Equal(t, 10*time.Second, cfg.Timeout("/query", "query", 0))
+	require.Equal(t, 30*time.Second, cfg.Timeout("/mutate", "mutation", 0))
+	require.Equal(t, time.Minute, cfg.Timeout("/mutate", "bulk-mutation", 0), "the purpose wins over the route")
+
+	require.Equal(t, 2*time.Second, cfg.Timeout("/query", "query", 2*time.Second))
+	require.Equal(t, 10*time.Second, cfg.Timeout("/query", "query", time.Hour), "capped by the configured timeout")
+	cfg.Max = 5 * time.Minute
+	require.Equal(t, 5*time.Minute, cfg.Timeout("/query", "query", time.Hour), "capped by max")
+
+	require.Zero(t, TimeoutConfig{}.Timeout("/query", "query", 0))
+	require.Equal(t, time.Hour, TimeoutConfig{}.Timeout("/query", "query", time.Hour))
+}
@@ -0,0 +1,91 @@
+package config
+
+import "fmt"
+
+// ClientTLS configures the connections from Otter to an alpha, both gRPC
+// and HTTP. In YAML it is either a boolean or a map:
+//
+//	tls: true                          # system roots
+//	tls: {ca_file: ca.crt}             # a private CA; enabled is implied
+//	tls: {ca_file: ca.crt, cert_file: otter.crt, key_file: otter.key}  # mTLS
+type ClientTLS struct {
+	Enabled            bool   `yaml:"enabled"`
+	CAFile             string `yaml:"ca_file,omitempty"`   // CA bundle verifying the alpha; system roots when empty
+	CertFile           string `yaml:"cert_file,omitempty"` // client certificate presented to the alpha
+	KeyFile            string `yaml:"key_file,omitempty"`
+	ServerName         string `yaml:"server_name,omitempty"` // name verified in the alpha's certificate; the host by default
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify,omitempty"`
+}
+
+func (t *ClientTLS) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var enabled bool
+	if err := unmarshal(&enabled); err == nil {
+		*t = ClientTLS{Enabled: enabled}
+		return nil
+	}
+
+	type plain ClientTLS
+	p := plain{Enabled: true}
+	if err := unmarshal(&p); err != nil {
+		return fmt.Errorf("tls must be a boolean or {ca_file, cert_file, key_file, server_name, insecure_skip_verify}: %w", err)
+	}
+	*t = ClientTLS(p)
+	return nil
+}
+
+func (t ClientTLS) MarshalYAML() (interface{}, error) {
+	if t == (ClientTLS{Enabled: t.Enabled}) {
+		return t.Enabled, nil
+	}
+	type plain ClientTLS
+	return plain(t), nil
+}
+
+// ServerTLS configures TLS termination on the proxy and WebSocket
+// listeners. It is enabled when a certificate is set.
+type ServerTLS struct {
+	CertFile     string `yaml:"cert_file,omitempty"`
+	KeyFile      string `yaml:"key_file,omitempty"`
+	ClientCAFile string `yaml:"client_ca_file,omitempty"` // verify client certificates against this bundle
+	ClientAuth   string `yaml:"client_auth,omitempty"`    // require (default with client_ca_file) or optional
+}
+
+// ClientAuth modes of ServerTLS.
+var ClientAuthModes = []string{"require", "optional"}
+
+func (t ServerTLS) Enabled() bool {
+	return t.CertFile != ""
+}
+
+// WithDefaults requires client certificates when a client CA is set.
+func (t ServerTLS) WithDefaults() ServerTLS {
+	if t.ClientCAFile != "" && t.ClientAuth == "" {
+		t.ClientAuth = "require"
+	}
+	return t
+}
+
+// WithEndpointTLS returns c with dgraph_tls applied to every endpoint,
+// configured or discovered, that has no tls setting of its own.
+func (c Config) WithEndpointTLS() Config {
+	if c.DgraphTLS == (ClientTLS{}) {
+		return c
+	}
+	apply := func(endpoints []Endpoint) []Endpoint {
+		out := make([]Endpoint, len(endpoints))
+		for i, ep := range endpoints {
+			if ep.TLS == (ClientTLS{}) {
+				ep.TLS = c.DgraphTLS
+			}
+			out[i] = ep
+		}
+		return out
+	}
+	c.DgraphEndpoints = apply(c.DgraphEndpoints)
+	groups := make(map[string][]Endpoint, len(c.Groups))
+	for name, eps := range c.Groups {
+		groups[name] = apply(eps)
+	}
+	c.Groups = groups
+	return c
+}
@@ -0,0 +1,411 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PoolBalancerTypes lists the single-pool strategies, accepted both for
+// balancer_type and group_balancer_type.
+var PoolBalancerTypes = []string{
+	"round-robin", "round-robin-healthy", "least-inflight",
+	"ewma", "peak-ewma", "consistent-hash",
+}
+
+// BalancerTypes lists the values accepted for balancer_type.
+var BalancerTypes = append(append([]string{}, PoolBalancerTypes...), "defined", "purposeful")
+
+var discoveredGroup = regexp.MustCompile(`^(leaders|followers|group-[0-9]+)$`)
+
+// Validate checks c for values Otter cannot run with and returns every
+// problem found, each prefixed with the YAML path of the offending field.
+func (c Config) Validate() error {
+	var errs []error
+	add := func(path, format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("%s: %s", path, fmt.Sprintf(format, args...)))
+	}
+
+	defined := c.BalancerType == "defined" || c.BalancerType == "purposeful"
+	if !oneOf(c.BalancerType, BalancerTypes...) {
+		add("balancer_type", "unknown balancer %q, expected one of %v", c.BalancerType, BalancerTypes)
+	}
+	if c.GroupBalancer != "" && !oneOf(c.GroupBalancer, PoolBalancerTypes...) {
+		add("group_balancer_type", "unknown group balancer %q, expected one of %v", c.GroupBalancer, PoolBalancerTypes)
+	}
+	if defined && len(c.Groups) == 0 && !c.Discovery.Enabled() {
+		add("groups", "balancer_type %q needs at least one group", c.BalancerType)
+	}
+
+	if !c.HTTPEnabled() && !c.WebSocketEnabled() {
+		add("enable_http", "enable_http and enable_websocket are both false, nothing to run")
+	}
+	if c.HTTPEnabled() {
+		checkPort(add, "proxy_port", c.ProxyPort)
+	}
+	if c.WebSocketEnabled() {
+		checkPort(add, "websocket_port", c.WebSocketPort)
+	}
+	if c.HTTPEnabled() && c.WebSocketEnabled() && c.ProxyPort != 0 && c.ProxyPort == c.WebSocketPort {
+		add("websocket_port", "same port as proxy_port (%d)", c.ProxyPort)
+	}
+	if c.Ratel != "" {
+		if err := checkHostPort(c.Ratel); err != nil {
+			add("ratel", "%v", err)
+		}
+	}
+	if (c.DgraphUser == "") != (c.DgraphPassword == "") {
+		add("dgraph_user", "dgraph_user and dgraph_password must be set together")
+	}
+
+	checkEndpoints(add, "dgraph_endpoints", c.DgraphEndpoints)
+	for _, name := range sortedKeys(c.Groups) {
+		checkEndpoints(add, "groups."+name, c.Groups[name])
+		if len(c.Groups[name]) == 0 {
+			add("groups."+name, "group has no endpoints")
+		}
+	}
+
+	for _, purpose := range sortedKeys(c.Fallbacks) {
+		for i, name := range c.Fallbacks[purpose] {
+			if !c.knownGroup(name) {
+				add(fmt.Sprintf("fallbacks.%s[%d]", purpose, i), "unknown group %q", name)
+			}
+		}
+	}
+
+	h := c.HealthCheck
+	if !oneOf(h.Mode, "", "http", "grpc", "both") {
+		add("health_check.mode", "unknown mode %q, expected http, grpc or both", h.Mode)
+	}
+	checkNonNegative(add, "health_check.interval", int64(h.Interval))
+	checkNonNegative(add, "health_check.timeout", int64(h.Timeout))
+	checkNonNegative(add, "health_check.failure_threshold", int64(h.FailureThreshold))
+	checkNonNegative(add, "health_check.success_threshold", int64(h.SuccessThreshold))
+
+	checkNonNegative(add, "outlier_detection.consecutive_errors", int64(c.Outlier.ConsecutiveErrors))
+	checkNonNegative(add, "outlier_detection.base_ejection", int64(c.Outlier.BaseEjection))
+	checkNonNegative(add, "outlier_detection.max_ejection", int64(c.Outlier.MaxEjection))
+	checkNonNegative(add, "ewma.decay", int64(c.EWMA.Decay))
+	checkNonNegative(add, "ewma.failure_penalty", int64(c.EWMA.FailurePenalty))
+	checkNonNegative(add, "consistent_hash.replicas", int64(c.ConsistentHash.Replicas))
+	checkNonNegative(add, "classify.bulk_threshold", int64(c.Classify.BulkThreshold))
+
+	checkNonNegative(add, "shutdown_timeout", int64(c.ShutdownTimeout))
+
+	checkAuth(add, "auth", c.Auth)
+	checkPolicy(add, "authz.", c.Authz)
+	checkFile(add, "authz.policy_file", c.Authz.PolicyFile)
+
+	for i, user := range c.DgraphACL.Users {
+		at := fmt.Sprintf("dgraph_acl.users[%d]", i)
+		if (user.Subject == "") == (user.Group == "") {
+			add(at, "set exactly one of subject and group")
+		}
+		if user.User == "" {
+			add(at+".user", "must not be empty")
+		}
+		if (user.Password == "") == (user.PasswordFile == "") {
+			add(at, "set exactly one of password and password_file")
+		}
+		checkFile(add, at+".password_file", user.PasswordFile)
+	}
+	if c.DgraphACL.Unmatched != "" && !oneOf(c.DgraphACL.Unmatched, ACLShared, ACLDeny) {
+		add("dgraph_acl.unmatched", "unknown policy %q, expected shared or deny", c.DgraphACL.Unmatched)
+	}
+	checkNonNegative(add, "dgraph_acl.idle_timeout", int64(c.DgraphACL.IdleTimeout))
+
+	c.checkTenancy(add)
+
+	for i, limit := range c.RateLimits {
+		at := fmt.Sprintf("rate_limits[%d]", i)
+		if !oneOf(limit.By, RateKeys...) {
+			add(at+".by", "unknown key %q, expected one of %v", limit.By, RateKeys)
+		}
+		checkBudgets(add, at, RateBudgets{Query: limit.Query, Mutation: limit.Mutation, Upsert: limit.Upsert})
+		for _, key := range sortedKeys(limit.Overrides) {
+			checkBudgets(add, at+".overrides."+key, limit.Overrides[key])
+		}
+	}
+
+	c.checkConcurrency(add)
+
+	checkNonNegative(add, "timeouts.default", int64(c.Timeouts.Default))
+	checkNonNegative(add, "timeouts.max", int64(c.Timeouts.Max))
+	for _, route := range sortedKeys(c.Timeouts.Routes) {
+		if !strings.HasPrefix(route, "/") {
+			add("timeouts.routes", "route %q must start with /", route)
+		}
+		checkNonNegative(add, "timeouts.routes."+route, int64(c.Timeouts.Routes[route]))
+	}
+	for _, purpose := range sortedKeys(c.Timeouts.Purposes) {
+		checkNonNegative(add, "timeouts.purposes."+purpose, int64(c.Timeouts.Purposes[purpose]))
+	}
+
+	checkNonNegative(add, "retries.attempts", int64(c.Retries.Attempts))
+	if c.Retries.Budget < 0 {
+		add("retries.budget", "must not be negative")
+	}
+	checkNonNegative(add, "retries.budget_burst", int64(c.Retries.BudgetBurst))
+	checkNonNegative(add, "retries.backoff", int64(c.Retries.Backoff))
+	checkNonNegative(add, "retries.max_backoff", int64(c.Retries.MaxBackoff))
+	checkNonNegative(add, "retries.aborted_upserts", int64(c.Retries.AbortedUpserts))
+	checkNonNegative(add, "retries.idempotency_ttl", int64(c.Retries.IdempotencyTTL))
+	checkNonNegative(add, "retries.idempotency_keys", int64(c.Retries.IdempotencyKeys))
+
+	checkServerTLS(add, "tls", c.TLS)
+	checkClientTLS(add, "dgraph_tls", c.DgraphTLS)
+
+	for i, zero := range c.Discovery.Zeros {
+		if err := checkHostPort(zero); err != nil {
+			add(fmt.Sprintf("discovery.zeros[%d]", i), "%v", err)
+		}
+	}
+	checkNonNegative(add, "discovery.interval", int64(c.Discovery.Interval))
+	checkNonNegative(add, "discovery.timeout", int64(c.Discovery.Timeout))
+	checkClientTLS(add, "discovery.tls", c.Discovery.TLS)
+	for _, purpose := range sortedKeys(c.Discovery.Purposes) {
+		if name := c.Discovery.Purposes[purpose]; !discoveredGroup.MatchString(name) {
+			add("discovery.purposes."+purpose, "unknown discovered group %q, expected leaders, followers or group-<id>", name)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// knownGroup reports whether a fallback chain may refer to name.
+func (c Config) knownGroup(name string) bool {
+	if _, ok := c.Groups[name]; ok {
+		return true
+	}
+	return name == "default" || name == "any" || (c.Discovery.Enabled() && discoveredGroup.MatchString(name))
+}
+
+func checkEndpoints(add func(path, format string, args ...interface{}), path string, endpoints []Endpoint) {
+	seen := make(map[string]bool)
+	for i, ep := range endpoints {
+		at := fmt.Sprintf("%s[%d]", path, i)
+		if err := checkHostPort(ep.Addr); err != nil {
+			add(at, "%v", err)
+		}
+		if ep.HTTP != "" {
+			if err := checkHostPort(ep.HTTP); err != nil {
+				add(at+".http", "%v", err)
+			}
+		}
+		checkClientTLS(add, at+".tls", ep.TLS)
+		if ep.Weight < 0 {
+			add(at+".weight", "must not be negative, got %d", ep.Weight)
+		}
+		if seen[ep.Addr] {
+			add(at, "duplicate endpoint %q", ep.Addr)
+		}
+		seen[ep.Addr] = true
+	}
+}
+
+func checkAuth(add func(path, format string, args ...interface{}), path string, a AuthConfig) {
+	seen := make(map[string]bool)
+	for i, key := range a.APIKeys {
+		at := fmt.Sprintf("%s.api_keys[%d]", path, i)
+		if key.Key == "" {
+			add(at+".key", "must not be empty")
+		} else if seen[key.Key] {
+			add(at+".key", "duplicate key")
+		}
+		seen[key.Key] = true
+		if key.Subject == "" {
+			add(at+".subject", "must not be empty")
+		}
+	}
+	checkFile(add, path+".api_keys_file", a.APIKeysFile)
+
+	if a.HTTP.Default != "" && !oneOf(a.HTTP.Default, RoutePublic, RouteRequired) {
+		add(path+".http.default", "unknown policy %q, expected public or required", a.HTTP.Default)
+	}
+	required := a.HTTP.Default == RouteRequired
+	for _, route := range sortedKeys(a.HTTP.Routes) {
+		required = required || a.HTTP.Routes[route] == RouteRequired
+		if !strings.HasPrefix(route, "/") {
+			add(path+".http.routes", "route %q must start with /", route)
+		}
+		if policy := a.HTTP.Routes[route]; !oneOf(policy, RoutePublic, RouteRequired) {
+			add(path+".http.routes."+route, "unknown policy %q, expected public or required", policy)
+		}
+	}
+	if required && !a.Enabled() {
+		add(path+".http", "routes require authentication but no method is configured")
+	}
+
+	j := a.JWT
+	if j.HMACSecret != "" && j.HMACSecretFile != "" {
+		add(path+".jwt", "hmac_secret and hmac_secret_file are exclusive")
+	}
+	checkFile(add, path+".jwt.hmac_secret_file", j.HMACSecretFile)
+	checkFile(add, path+".jwt.jwks_file", j.JWKSFile)
+	if !j.Enabled() && (j.Issuer != "" || j.Audience != "" || j.GroupsClaim != "") {
+		add(path+".jwt", "needs hmac_secret, hmac_secret_file or jwks_file")
+	}
+}
+
+func (c Config) checkTenancy(add func(path, format string, args ...interface{})) {
+	namespaces := make(map[uint64]string)
+	for _, name := range sortedKeys(c.Tenancy.Tenants) {
+		t := c.Tenancy.Tenants[name]
+		at := "tenancy.tenants." + name
+		if other, ok := namespaces[t.Namespace]; ok {
+			add(at+".namespace", "namespace %d is also used by tenant %s", t.Namespace, other)
+		}
+		namespaces[t.Namespace] = name
+		switch {
+		case t.User == "" && (t.Password != "" || t.PasswordFile != ""):
+			add(at, "password given without user")
+		case t.User == "" && c.DgraphUser == "":
+			add(at+".user", "needs user or dgraph_user to log in to the namespace")
+		case t.User != "" && (t.Password == "") == (t.PasswordFile == ""):
+			add(at, "set exactly one of password and password_file")
+		}
+		checkFile(add, at+".password_file", t.PasswordFile)
+		for i, group := range t.Groups {
+			if c.BalancerType != "defined" && c.BalancerType != "purposeful" {
+				add(at+".groups", "needs balancer_type defined or purposeful")
+				break
+			}
+			if !c.knownGroup(group) {
+				add(fmt.Sprintf("%s.groups[%d]", at, i), "unknown group %q", group)
+			}
+		}
+		checkNonNegative(add, at+".max_concurrent", int64(t.MaxConcurrent))
+	}
+}
+
+func (c Config) checkConcurrency(add func(path, format string, args ...interface{})) {
+	cc := c.Concurrency
+	checkNonNegative(add, "concurrency.max_per_endpoint", int64(cc.MaxPerEndpoint))
+	checkNonNegative(add, "concurrency.queue_size", int64(cc.QueueSize))
+	checkNonNegative(add, "concurrency.queue_timeout", int64(cc.QueueTimeout))
+	checkNonNegative(add, "concurrency.adaptive.min_limit", int64(cc.Adaptive.MinLimit))
+	checkNonNegative(add, "concurrency.adaptive.initial_limit", int64(cc.Adaptive.InitialLimit))
+	checkNonNegative(add, "concurrency.adaptive.latency", int64(cc.Adaptive.Latency))
+	if cc.Adaptive.Backoff < 0 || cc.Adaptive.Backoff >= 1 {
+		add("concurrency.adaptive.backoff", "must be between 0 and 1")
+	}
+	if cc.Adaptive.BatchShare < 0 || cc.Adaptive.BatchShare > 1 {
+		add("concurrency.adaptive.batch_share", "must be between 0 and 1")
+	}
+	if cc.MaxPerEndpoint > 0 && cc.Adaptive.MinLimit > cc.MaxPerEndpoint {
+		add("concurrency.adaptive.min_limit", "exceeds max_per_endpoint %d", cc.MaxPerEndpoint)
+	}
+	for _, group := range sortedKeys(cc.Groups) {
+		at := "concurrency.groups." + group
+		if c.BalancerType != "defined" && c.BalancerType != "purposeful" {
+			add("concurrency.groups", "needs balancer_type defined or purposeful")
+			break
+		}
+		if !c.knownGroup(group) {
+			add(at, "unknown group %q", group)
+		}
+		checkNonNegative(add, at, int64(cc.Groups[group]))
+	}
+}
+
+func checkBudgets(add func(path, format string, args ...interface{}), path string, b RateBudgets) {
+	for _, class := range []struct {
+		name   string
+		bucket Bucket
+	}{{"query", b.Query}, {"mutation", b.Mutation}, {"upsert", b.Upsert}} {
+		if class.bucket.Rate < 0 {
+			add(path+"."+class.name+".rate", "must not be negative")
+		}
+		checkNonNegative(add, path+"."+class.name+".burst", int64(class.bucket.Burst))
+	}
+}
+
+func checkServerTLS(add func(path, format string, args ...interface{}), path string, t ServerTLS) {
+	checkKeyPair(add, path, t.CertFile, t.KeyFile)
+	checkFile(add, path+".client_ca_file", t.ClientCAFile)
+	if t.ClientCAFile != "" && !t.Enabled() {
+		add(path+".client_ca_file", "needs cert_file and key_file")
+	}
+	if t.ClientAuth != "" {
+		if !oneOf(t.ClientAuth, ClientAuthModes...) {
+			add(path+".client_auth", "unknown mode %q, expected one of %v", t.ClientAuth, ClientAuthModes)
+		} else if t.ClientCAFile == "" {
+			add(path+".client_auth", "needs client_ca_file")
+		}
+	}
+}
+
+func checkClientTLS(add func(path, format string, args ...interface{}), path string, t ClientTLS) {
+	if !t.Enabled && t != (ClientTLS{}) {
+		add(path, "settings given but enabled is false")
+	}
+	checkFile(add, path+".ca_file", t.CAFile)
+	checkKeyPair(add, path, t.CertFile, t.KeyFile)
+}
+
+func checkKeyPair(add func(path, format string, args ...interface{}), path, cert, key string) {
+	if (cert == "") != (key == "") {
+		add(path, "cert_file and key_file must be set together")
+	}
+	checkFile(add, path+".cert_file", cert)
+	checkFile(add, path+".key_file", key)
+}
+
+func checkFile(add func(path, format string, args ...interface{}), path, name string) {
+	if name == "" {
+		return
+	}
+	if _, err := os.Stat(name); err != nil {
+		add(path, "%v", err)
+	}
+}
+
+func checkHostPort(addr string) error {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("invalid address %q: expected host:port", addr)
+	}
+	if host == "" {
+		return fmt.Errorf("invalid address %q: missing host", addr)
+	}
+	if port, err := strconv.Atoi(portStr); err != nil || port <= 0 || port > 65535 {
+		return fmt.Errorf("invalid port in address %q", addr)
+	}
+	return nil
+}
+
+func checkPort(add func(path, format string, args ...interface{}), path string, port int) {
+	if port < 0 || port > 65535 {
+		add(path, "port %d out of range", port)
+	}
+}
+
+func checkNonNegative(add func(path, format string, args ...interface{}), path string, v int64) {
+	if v < 0 {
+		add(path, "must not be negative")
+	}
+}
+
+func oneOf(v string, values ...string) bool {
+	for _, value := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,113 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	valid := Config{
+		BalancerType:    "defined",
+		ProxyPort:       8084,
+		WebSocketPort:   8089,
+		DgraphEndpoints: EndpointsFromAddrs("localhost:9080"),
+		Groups:          map[string][]Endpoint{"query": EndpointsFromAddrs("localhost:9081")},
+		Fallbacks:       map[string][]string{"query": {"default", "any"}},
+	}
+	require.NoError(t, valid.Validate())
+
+	bad := valid
+	bad.BalancerType = "ewmaa"
+	bad.GroupBalancer = "defined"
+	bad.DgraphEndpoints = []Endpoint{{Addr: "localhost"}, {Addr: "localhost:9080", HTTP: "x"}}
+	bad.Fallbacks = map[string][]string{"query": {"leaders"}}
+	bad.HealthCheck.Mode = "tcp"
+	bad.ShutdownTimeout = -time.Second
+	bad.TLS = ServerTLS{CertFile: "missing.crt", ClientAuth: "optional"}
+	bad.Auth.HTTP = HTTPAuth{Default: "required", Routes: map[string]string{"health": "open"}}
+	bad.Tenancy.Tenants = map[string]Tenant{
+		"acme":   {Namespace: 1, Groups: []string{"query", "nope"}},
+		"globex": {Namespace: 1, User: "globex", MaxConcurrent: -1},
+	}
+	bad.Authz = AuthzConfig{Default: "maybe", Rules: []PolicyRule{{Groups: []string{"admin"}}, {Allow: []string{"delete"}}}}
+	bad.RateLimits = []RateLimit{{By: "key", Query: Bucket{Rate: -1}, Overrides: map[string]RateBudgets{"acme": {Upsert: Bucket{Rate: 1, Burst: -2}}}}}
+	bad.Concurrency = ConcurrencyConfig{MaxPerEndpoint: -1, Groups: map[string]int{"query": 4}, Adaptive: AdaptiveConfig{Backoff: 1.5, BatchShare: 2}}
+	bad.Timeouts = TimeoutConfig{Default: -time.Second, Routes: map[string]time.Duration{"query": time.Second}}
+	bad.Retries = RetryConfig{Attempts: -1, Budget: -0.5}
+	bad.DgraphACL = ACLConfig{Users: []ACLUser{{Subject: "alice", Group: "admin", Password: "x"}}, Unmatched: "drop"}
+	err := bad.Validate()
+	require.Error(t, err)
+	for _, want := range []string{
+		`balancer_type: unknown balancer "ewmaa"`,
+		`group_balancer_type: unknown group balancer "defined"`,
+		`dgraph_endpoints[0]: invalid address "localhost": expected host:port`,
+		`dgraph_endpoints[1].http: invalid address "x"`,
+		`fallbacks.query[0]: unknown group "leaders"`,
+		`health_check.mode: unknown mode "tcp"`,
+		`shutdown_timeout: must not be negative`,
+		`tls: cert_file and key_file must be set together`,
+		`tls.cert_file: stat missing.crt: no such file or directory`,
+		`tls.client_auth: needs client_ca_file`,
+		`auth.http.routes: route "health" must start with /`,
+		`auth.http.routes.health: unknown policy "open"`,
+		`auth.http: routes require authentication but no method is configured`,
+		`dgraph_acl.users[0]: set exactly one of subject and group`,
+		`dgraph_acl.users[0].user: must not be empty`,
+		`dgraph_acl.unmatched: unknown policy "drop"`,
+		`authz.default: unknown policy "maybe"`,
+		`authz.rules[0]: allows and denies nothing`,
+		`authz.rules[1]: unknown operation "delete"`,
+		`tenancy.tenants.acme.user: needs user or dgraph_user`,
+		`tenancy.tenants.acme.groups: needs balancer_type defined or purposeful`,
+		`tenancy.tenants.globex.namespace: namespace 1 is also used by tenant acme`,
+		`tenancy.tenants.globex: set exactly one of password and password_file`,
+		`tenancy.tenants.globex.max_concurrent: must not be negative`,
+		`rate_limits[0].by: unknown key "key"`,
+		`rate_limits[0].query.rate: must not be negative`,
+		`rate_limits[0].overrides.acme.upsert.burst: must not be negative`,
+		`concurrency.max_per_endpoint: must not be negative`,
+		`concurrency.groups: needs balancer_type defined or purposeful`,
+		`concurrency.adaptive.backoff: must be between 0 and 1`,
+		`concurrency.adaptive.batch_share: must be between 0 and 1`,
+		`timeouts.default: must not be negative`,
+		`timeouts.routes: route "query" must start with /`,
+		`retries.attempts: must not be negative`,
+		`retries.budget: must not be negative`,
+	} {
+		require.Contains(t, err.Error(), want)
+	}
+
+	off := false
+	bad.EnableHTTP, bad.EnableWebSocket = &off, &off
+	bad.ProxyPort = 70000
+	err = bad.Validate()
+	require.ErrorContains(t, err, "enable_http: enable_http and enable_websocket are both false")
+	require.NotContains(t, err.Error(), "proxy_port", "ports of disabled servers are not checked")
+
+	bad.Discovery.Zeros = []string{"localhost:6080"}
+	require.NotContains(t, bad.Validate().Error(), "fallbacks", "discovered groups are known with discovery")
+}
+
+func TestLoadConfigStrict(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	t.Setenv("CONFIG_FILE", path)
+	t.Setenv("DGRAPH_ENDPOINTS", "localhost:9080")
+
+	require.NoError(t, os.WriteFile(path, []byte("ratel-graphql: true\n"), 0644))
+	_, err := LoadConfig()
+	require.ErrorContains(t, err, "field ratel-graphql not found")
+
+	require.NoError(t, os.WriteFile(path, []byte("ratel_graphql: true\n"), 0644))
+	t.Setenv("ENABLE_HTTP", "yes please")
+	_, err = LoadConfig()
+	require.ErrorContains(t, err, `invalid ENABLE_HTTP environment variable "yes please"`)
+
+	t.Setenv("ENABLE_HTTP", "false")
+	cfg, err := LoadConfig()
+	require.NoError(t, err)
+	require.False(t, *cfg.EnableHTTP)
+}
@@ -0,0 +1,38 @@
+package config
+
+import (
+	"log"
+	"os"
+	"time"
+)
+
+// WatchFile calls onChange whenever the modification time or the size of
+// path changes, checking every interval until stop is closed. Editors that
+// replace the file instead of writing it in place are handled as well.
+func WatchFile(path string, interval time.Duration, stop <-chan struct{}, onChange func()) {
+	last, err := os.Stat(path)
+	if err != nil {
+		log.Printf("Warning: Cannot watch config file %q: %v", path, err)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			// Mid-replace or removed: keep the last config and retry.
+			continue
+		}
+		if last != nil && info.ModTime().Equal(last.ModTime()) && info.Size() == last.Size() {
+			continue
+		}
+		last = info
+		onChange()
+	}
+}
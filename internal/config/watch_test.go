@@ -0,0 +1,33 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatchFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("proxy_port: 8084\n"), 0644))
+
+	changes := make(chan struct{}, 1)
+	stop := make(chan struct{})
+	defer close(stop)
+	go WatchFile(path, 10*time.Millisecond, stop, func() { changes <- struct{}{} })
+
+	select {
+	case <-changes:
+		t.Fatal("unchanged file reported as changed")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	require.NoError(t, os.WriteFile(path, []byte("proxy_port: 8085\nbalancer_type: ewma\n"), 0644))
+	select {
+	case <-changes:
+	case <-time.After(time.Second):
+		t.Fatal("change not reported")
+	}
+}
@@ -2,75 +2,177 @@ package dgraph
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
+	"sync"
+	"time"
 
 	"github.com/dgraph-io/dgo/v240"
 	"github.com/dgraph-io/dgo/v240/protos/api"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
 )
 
 type Client struct {
-	dg *dgo.Dgraph
+	dg   *dgo.Dgraph
+	conn *grpc.ClientConn // nil for a session, which uses its root's
+
+	// A session acts as another ACL user on the connection of root.
+	root  *Client
+	creds *Credentials
+
+	mu       sync.Mutex
+	inflight int
+	retired  bool
+	closed   bool
+	sessions map[Credentials]*session
+	idle     time.Duration
+	swept    time.Time
 }
 
-func NewClient(endpoint string, user, password string) (*Client, error) {
+// NewClient connects to the alpha at endpoint, over TLS when tlsConfig is
+// set and in plaintext otherwise.
+func NewClient(endpoint string, user, password string, tlsConfig *tls.Config) (*Client, error) {
 	fmt.Println("Creating Dgraph client...")
 	fmt.Println("Endpoint:", endpoint)
-	opts := []dgo.ClientOption{
-		dgo.WithGrpcOption(grpc.WithTransportCredentials(insecure.NewCredentials())),
+	creds := insecure.NewCredentials()
+	if tlsConfig != nil {
+		creds = credentials.NewTLS(tlsConfig)
+	}
+	conn, err := grpc.NewClient(endpoint, grpc.WithTransportCredentials(creds), grpc.WithStatsHandler(sentTracker{}))
+	if err != nil {
+		return nil, fmt.Errorf("could not create Dgraph client: %w", err)
 	}
 
+	// dgo.NewClient hides its connection; build the client on our own so
+	// sessions of other users can share it.
+	c := &Client{dg: dgo.NewDgraphClient(api.NewDgraphClient(conn)), conn: conn, idle: DefaultSessionIdle}
 	if user != "" && password != "" {
-		opts = append(opts, dgo.WithACLCreds(user, password))
+		c.creds = &Credentials{User: user, Password: password}
+		ctx, cancel := context.WithTimeout(context.Background(), loginTimeout)
+		defer cancel()
+		if err := c.dg.Login(ctx, user, password); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("could not create Dgraph client: failed to sign in user: %w", err)
+		}
 	}
+	return c, nil
+}
 
-	client, err := dgo.NewClient(endpoint, opts...)
-	if err != nil {
-		return nil, fmt.Errorf("could not create Dgraph client: %w", err)
+func (c *Client) Close() {
+	c = c.owner()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.close()
+}
+
+func (c *Client) close() {
+	if !c.closed {
+		c.closed = true
+		c.sessions = nil
+		c.conn.Close()
 	}
+}
 
-	return &Client{dg: client}, nil
+// Retire closes the client once it has been idle after grace. Requests
+// that picked the client before it was replaced can still start during
+// grace and run to completion.
+func (c *Client) Retire(grace time.Duration) {
+	c = c.owner()
+	time.AfterFunc(grace, func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.retired = true
+		if c.inflight == 0 {
+			c.close()
+		}
+	})
 }
 
-func (c *Client) Close() {
-	c.dg.Close()
+// Inflight returns the number of calls currently running on the client.
+func (c *Client) Inflight() int {
+	c = c.owner()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.inflight
+}
+
+// owner returns the client holding the connection: the root of a session,
+// c itself otherwise.
+func (c *Client) owner() *Client {
+	if c.root != nil {
+		return c.root
+	}
+	return c
+}
+
+func (c *Client) acquire() {
+	c = c.owner()
+	c.mu.Lock()
+	c.inflight++
+	c.mu.Unlock()
+}
+
+func (c *Client) release() {
+	c = c.owner()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.inflight--
+	if c.retired && c.inflight == 0 {
+		c.close()
+	}
 }
 
 func (c *Client) Query(ctx context.Context, query string) (*api.Response, error) {
-	txn := c.dg.NewReadOnlyTxn()
-	defer txn.Discard(ctx)
+	c.acquire()
+	defer c.release()
 
-	resp, err := txn.Query(ctx, query)
+	sentCtx, sent := trackSent(ctx)
+	resp, err := withRelogin(ctx, c, func() (*api.Response, error) {
+		txn := c.dg.NewReadOnlyTxn()
+		defer txn.Discard(ctx)
+		return txn.Query(sentCtx, query)
+	})
 	if err != nil {
-		return nil, fmt.Errorf("error querying Dgraph: %w", err)
+		return nil, fmt.Errorf("error querying Dgraph: %w", notSent(err, sent))
 	}
 	return resp, nil
 }
 
 func (c *Client) Mutate(ctx context.Context, mutation *api.Mutation) (*api.Response, error) {
-	txn := c.dg.NewTxn()
-	defer txn.Discard(ctx)
+	c.acquire()
+	defer c.release()
 
-	resp, err := txn.Mutate(ctx, mutation)
+	sentCtx, sent := trackSent(ctx)
+	resp, err := withRelogin(ctx, c, func() (*api.Response, error) {
+		txn := c.dg.NewTxn()
+		defer txn.Discard(ctx)
+		return txn.Mutate(sentCtx, mutation)
+	})
 	if err != nil {
-		return nil, fmt.Errorf("error mutating Dgraph: %w", err)
+		return nil, fmt.Errorf("error mutating Dgraph: %w", notSent(err, sent))
 	}
 	return resp, nil
 }
 
 func (c *Client) Upsert(ctx context.Context, query string, mutations []*api.Mutation, commitNow bool) (*api.Response, error) {
-	txn := c.dg.NewTxn()
-	defer txn.Discard(ctx)
+	c.acquire()
+	defer c.release()
 
 	req := &api.Request{
 		Query:     query,
 		Mutations: mutations,
 		CommitNow: commitNow,
 	}
-	resp, err := txn.Do(ctx, req)
+	sentCtx, sent := trackSent(ctx)
+	resp, err := withRelogin(ctx, c, func() (*api.Response, error) {
+		txn := c.dg.NewTxn()
+		defer txn.Discard(ctx)
+		return txn.Do(sentCtx, req)
+	})
 	if err != nil {
-		return nil, fmt.Errorf("error performing upsert: %w", err)
+		return nil, fmt.Errorf("error performing upsert: %w", notSent(err, sent))
 	}
 	return resp, nil
 }
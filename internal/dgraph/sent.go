@@ -0,0 +1,54 @@
+package dgraph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"google.golang.org/grpc/stats"
+)
+
+// ErrNotSent marks the error of a call that failed before its request was
+// handed to a connection, so the alpha cannot have seen it.
+var ErrNotSent = errors.New("request not sent")
+
+type sentCtxKey struct{}
+
+// trackSent returns ctx recording whether a call made with it was sent.
+func trackSent(ctx context.Context) (context.Context, *atomic.Bool) {
+	sent := new(atomic.Bool)
+	return context.WithValue(ctx, sentCtxKey{}, sent), sent
+}
+
+// notSent wraps err with ErrNotSent unless the call was sent.
+func notSent(err error, sent *atomic.Bool) error {
+	if err == nil || sent.Load() {
+		return err
+	}
+	return fmt.Errorf("%w (%w)", err, ErrNotSent)
+}
+
+// sentTracker is the stats handler of every connection. gRPC reports the
+// headers of a call once its stream is open on a live transport, from
+// which point the alpha may receive it.
+type sentTracker struct{}
+
+func (sentTracker) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context {
+	return ctx
+}
+
+func (sentTracker) HandleRPC(ctx context.Context, s stats.RPCStats) {
+	if _, ok := s.(*stats.OutHeader); !ok {
+		return
+	}
+	if sent, ok := ctx.Value(sentCtxKey{}).(*atomic.Bool); ok {
+		sent.Store(true)
+	}
+}
+
+func (sentTracker) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (sentTracker) HandleConn(context.Context, stats.ConnStats) {}
@@ -0,0 +1,154 @@
+package dgraph
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/dgraph-io/dgo/v240"
+	"github.com/dgraph-io/dgo/v240/protos/api"
+	"github.com/golang-jwt/jwt/v5"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// DefaultSessionIdle is how long an unused session stays logged in.
+	DefaultSessionIdle = 10 * time.Minute
+
+	loginTimeout = 30 * time.Second
+	// refreshBefore is how long before expiry AccessJWT refreshes a token.
+	refreshBefore = time.Minute
+)
+
+// Credentials identify a Dgraph ACL user in a namespace.
+type Credentials struct {
+	User      string
+	Password  string
+	Namespace uint64
+}
+
+type session struct {
+	client   *Client
+	lastUsed time.Time
+	ready    chan struct{} // closed once the login finished
+	err      error
+}
+
+// SetSessionIdle sets how long sessions stay logged in without being used.
+func (c *Client) SetSessionIdle(idle time.Duration) {
+	c = c.owner()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.idle = idle
+}
+
+// As returns a client acting as the ACL user creds on c's connection, so
+// Dgraph applies that user's rules. Sessions are logged in on first use and
+// kept until they have been idle for the session idle time.
+func (c *Client) As(ctx context.Context, creds Credentials) (*Client, error) {
+	c = c.owner()
+	now := time.Now()
+
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil, fmt.Errorf("Dgraph client is closed")
+	}
+	c.sweep(now)
+	s, ok := c.sessions[creds]
+	if !ok {
+		if c.sessions == nil {
+			c.sessions = make(map[Credentials]*session)
+		}
+		s = &session{
+			client: &Client{dg: dgo.NewDgraphClient(api.NewDgraphClient(c.conn)), root: c, creds: &creds},
+			ready:  make(chan struct{}),
+		}
+		c.sessions[creds] = s
+	}
+	s.lastUsed = now
+	c.mu.Unlock()
+
+	if !ok {
+		s.err = s.client.login(ctx)
+		if s.err != nil {
+			c.mu.Lock()
+			delete(c.sessions, creds)
+			c.mu.Unlock()
+		}
+		close(s.ready)
+	}
+
+	select {
+	case <-s.ready:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if s.err != nil {
+		return nil, fmt.Errorf("Dgraph login as %s failed: %w", creds.User, s.err)
+	}
+	return s.client, nil
+}
+
+// Sessions returns the number of ACL sessions c holds.
+func (c *Client) Sessions() int {
+	c = c.owner()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.sessions)
+}
+
+// sweep drops the sessions idle for longer than c.idle, at most every half
+// idle period. c.mu is held.
+func (c *Client) sweep(now time.Time) {
+	if now.Sub(c.swept) < c.idle/2 {
+		return
+	}
+	c.swept = now
+	for creds, s := range c.sessions {
+		if now.Sub(s.lastUsed) > c.idle {
+			delete(c.sessions, creds)
+		}
+	}
+}
+
+func (c *Client) login(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, loginTimeout)
+	defer cancel()
+	return c.dg.LoginIntoNamespace(ctx, c.creds.User, c.creds.Password, c.creds.Namespace)
+}
+
+// AccessJWT returns the access token of c's ACL user, for calls made to the
+// alpha over HTTP. A token about to expire is refreshed first.
+func (c *Client) AccessJWT(ctx context.Context) (string, error) {
+	if c.creds == nil {
+		return "", nil
+	}
+	token := c.dg.GetJwt().AccessJwt //nolint:staticcheck // the only way to read the token dgo holds
+	var claims jwt.RegisteredClaims
+	if _, _, err := jwt.NewParser().ParseUnverified(token, &claims); err == nil &&
+		claims.ExpiresAt != nil && time.Until(claims.ExpiresAt.Time) > refreshBefore {
+		return token, nil
+	}
+	if err := c.dg.Relogin(ctx); err != nil {
+		if err := c.login(ctx); err != nil {
+			return "", err
+		}
+	}
+	return c.dg.GetJwt().AccessJwt, nil //nolint:staticcheck
+}
+
+// withRelogin runs call and, when Dgraph rejects c's credentials even after
+// dgo tried its refresh token, logs in again with the password and retries
+// once.
+func withRelogin[T any](ctx context.Context, c *Client, call func() (T, error)) (T, error) {
+	resp, err := call()
+	if c.creds == nil || status.Code(err) != codes.Unauthenticated {
+		return resp, err
+	}
+	if loginErr := c.login(ctx); loginErr != nil {
+		return resp, err
+	}
+	return call()
+}
@@ -0,0 +1,248 @@
+package dgraph
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/dgo/v240/protos/api"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// fakeAlpha logs users in and answers queries with the user of the access
+// token they carry.
+type fakeAlpha struct {
+	api.UnimplementedDgraphServer
+
+	mu        sync.Mutex
+	passwords map[string]string
+	ttl       time.Duration
+	valid     map[string]string // access token -> user
+	refresh   map[string]string // refresh token -> user
+	logins    int
+	refreshes int
+	issued    int
+}
+
+func (f *fakeAlpha) Login(_ context.Context, req *api.LoginRequest) (*api.Response, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	user := req.Userid
+	if req.RefreshToken != "" {
+		var ok bool
+		if user, ok = f.refresh[req.RefreshToken]; !ok {
+			return nil, status.Error(codes.Unauthenticated, "invalid refresh token")
+		}
+		f.refreshes++
+	} else {
+		if f.passwords[user] != req.Password {
+			return nil, status.Error(codes.Unauthenticated, "invalid username or password")
+		}
+		f.logins++
+	}
+
+	f.issued++
+	access, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		Subject:   user,
+		ID:        fmt.Sprint(f.issued),
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(f.ttl)),
+	}).SignedString([]byte("secret"))
+	if err != nil {
+		return nil, err
+	}
+	refresh := fmt.Sprintf("refresh-%d", f.issued)
+	f.valid[access], f.refresh[refresh] = user, user
+
+	payload, err := proto.Marshal(&api.Jwt{AccessJwt: access, RefreshJwt: refresh})
+	if err != nil {
+		return nil, err
+	}
+	return &api.Response{Json: payload}, nil
+}
+
+func (f *fakeAlpha) Query(ctx context.Context, _ *api.Request) (*api.Response, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	user := ""
+	if tokens := metadata.ValueFromIncomingContext(ctx, "accessjwt"); len(tokens) > 0 {
+		var ok bool
+		if user, ok = f.valid[tokens[0]]; !ok {
+			return nil, status.Error(codes.Unauthenticated, "unknown token")
+		}
+	}
+	return &api.Response{Json: []byte(fmt.Sprintf(`{"user":%q}`, user))}, nil
+}
+
+// revokeAll forgets every access token issued so far.
+func (f *fakeAlpha) revokeAll() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.valid = make(map[string]string)
+}
+
+func (f *fakeAlpha) counts() (logins, refreshes int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.logins, f.refreshes
+}
+
+func startAlpha(t *testing.T, ttl time.Duration) (*fakeAlpha, string) {
+	t.Helper()
+	alpha := &fakeAlpha{
+		passwords: map[string]string{"alice": "alice-pw", "bob": "bob-pw"},
+		ttl:       ttl,
+		valid:     make(map[string]string),
+		refresh:   make(map[string]string),
+	}
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	srv := grpc.NewServer()
+	api.RegisterDgraphServer(srv, alpha)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+	return alpha, lis.Addr().String()
+}
+
+func queriedUser(t *testing.T, c *Client) string {
+	t.Helper()
+	resp, err := c.Query(context.Background(), "{ q(func: uid(0x1)) { uid } }")
+	require.NoError(t, err)
+	return string(resp.Json)
+}
+
+func TestAsReusesSessionPerCredentials(t *testing.T) {
+	alpha, addr := startAlpha(t, time.Hour)
+	root, err := NewClient(addr, "", "", nil)
+	require.NoError(t, err)
+	defer root.Close()
+	ctx := context.Background()
+
+	alice, err := root.As(ctx, Credentials{User: "alice", Password: "alice-pw"})
+	require.NoError(t, err)
+	again, err := root.As(ctx, Credentials{User: "alice", Password: "alice-pw"})
+	require.NoError(t, err)
+	require.Same(t, alice, again)
+
+	bob, err := alice.As(ctx, Credentials{User: "bob", Password: "bob-pw"})
+	require.NoError(t, err)
+	require.NotSame(t, alice, bob)
+	require.Equal(t, 2, root.Sessions())
+
+	require.Equal(t, `{"user":"alice"}`, queriedUser(t, alice))
+	require.Equal(t, `{"user":"bob"}`, queriedUser(t, bob))
+	require.Equal(t, `{"user":""}`, queriedUser(t, root))
+	logins, _ := alpha.counts()
+	require.Equal(t, 2, logins)
+}
+
+func TestAsForgetsFailedLogins(t *testing.T) {
+	_, addr := startAlpha(t, time.Hour)
+	root, err := NewClient(addr, "", "", nil)
+	require.NoError(t, err)
+	defer root.Close()
+
+	_, err = root.As(context.Background(), Credentials{User: "alice", Password: "wrong"})
+	require.ErrorContains(t, err, "Dgraph login as alice failed")
+	require.Equal(t, 0, root.Sessions())
+
+	_, err = root.As(context.Background(), Credentials{User: "alice", Password: "alice-pw"})
+	require.NoError(t, err)
+	require.Equal(t, 1, root.Sessions())
+}
+
+func TestIdleSessionsAreSwept(t *testing.T) {
+	_, addr := startAlpha(t, time.Hour)
+	root, err := NewClient(addr, "", "", nil)
+	require.NoError(t, err)
+	defer root.Close()
+	root.SetSessionIdle(20 * time.Millisecond)
+
+	_, err = root.As(context.Background(), Credentials{User: "alice", Password: "alice-pw"})
+	require.NoError(t, err)
+	time.Sleep(50 * time.Millisecond)
+	_, err = root.As(context.Background(), Credentials{User: "bob", Password: "bob-pw"})
+	require.NoError(t, err)
+	require.Equal(t, 1, root.Sessions())
+}
+
+func TestSessionLogsInAgainWhenRejected(t *testing.T) {
+	alpha, addr := startAlpha(t, time.Hour)
+	root, err := NewClient(addr, "", "", nil)
+	require.NoError(t, err)
+	defer root.Close()
+
+	alice, err := root.As(context.Background(), Credentials{User: "alice", Password: "alice-pw"})
+	require.NoError(t, err)
+	alpha.revokeAll()
+
+	require.Equal(t, `{"user":"alice"}`, queriedUser(t, alice))
+	logins, _ := alpha.counts()
+	require.Equal(t, 2, logins)
+}
+
+func TestAccessJWTRefreshesExpiringTokens(t *testing.T) {
+	alpha, addr := startAlpha(t, 30*time.Second)
+	root, err := NewClient(addr, "", "", nil)
+	require.NoError(t, err)
+	defer root.Close()
+
+	token, err := root.AccessJWT(context.Background())
+	require.NoError(t, err)
+	require.Empty(t, token, "the shared client has no ACL user")
+
+	alice, err := root.As(context.Background(), Credentials{User: "alice", Password: "alice-pw"})
+	require.NoError(t, err)
+	first := alice.dg.GetJwt().AccessJwt //nolint:staticcheck
+	token, err = alice.AccessJWT(context.Background())
+	require.NoError(t, err)
+	require.NotEqual(t, first, token)
+	_, refreshes := alpha.counts()
+	require.Equal(t, 1, refreshes)
+}
+
+func TestNotSent(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := lis.Addr().String()
+	lis.Close()
+	down, err := NewClient(addr, "", "", nil)
+	require.NoError(t, err)
+	defer down.Close()
+
+	_, err = down.Mutate(context.Background(), &api.Mutation{SetNquads: []byte(`_:a <name> "a" .`)})
+	require.ErrorIs(t, err, ErrNotSent, "nothing listens on %s", addr)
+	require.Equal(t, codes.Unavailable, status.Code(err))
+
+	lis, err = net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	srv := grpc.NewServer()
+	api.RegisterDgraphServer(srv, drainingAlpha{})
+	go srv.Serve(lis)
+	defer srv.Stop()
+	c, err := NewClient(lis.Addr().String(), "", "", nil)
+	require.NoError(t, err)
+	defer c.Close()
+	_, err = c.Mutate(context.Background(), &api.Mutation{SetNquads: []byte(`_:a <name> "a" .`)})
+	require.Equal(t, codes.Unavailable, status.Code(err))
+	require.NotErrorIs(t, err, ErrNotSent, "the alpha received the call")
+}
+
+// drainingAlpha refuses every call it receives.
+type drainingAlpha struct {
+	api.UnimplementedDgraphServer
+}
+
+func (drainingAlpha) Query(context.Context, *api.Request) (*api.Response, error) {
+	return nil, status.Error(codes.Unavailable, "shutting down")
+}
@@ -0,0 +1,180 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/OpenDgraph/Otter/internal/config"
+)
+
+// Dgraph derives every port of a node from one offset: 7080+o for internal
+// traffic (the address Zero reports), 8080+o for HTTP and 9080+o for gRPC.
+const (
+	internalPort = 7080
+	httpPort     = 8080
+	grpcPort     = 9080
+)
+
+// Alpha is one live member of the cluster as reported by Zero.
+type Alpha struct {
+	Addr   string // internal address, host:7080+offset
+	Group  uint32
+	Leader bool
+}
+
+// Topology is the set of live alphas, sorted by address.
+type Topology struct {
+	Alphas []Alpha
+}
+
+// zeroState is the part of Zero's /state response Otter reads.
+type zeroState struct {
+	Groups map[string]struct {
+		Members map[string]struct {
+			Addr    string `json:"addr"`
+			GroupID uint32 `json:"groupId"`
+			Leader  bool   `json:"leader"`
+			AmDead  bool   `json:"amDead"`
+		} `json:"members"`
+	} `json:"groups"`
+}
+
+// Fetch reads the cluster membership from the Zero at zero (host:port of its
+// HTTP listener), reached with scheme.
+func Fetch(ctx context.Context, client *http.Client, scheme, zero string) (Topology, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, scheme+"://"+zero+"/state", nil)
+	if err != nil {
+		return Topology{}, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return Topology{}, fmt.Errorf("error fetching state from zero %s: %w", zero, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Topology{}, fmt.Errorf("zero %s answered /state with status %d", zero, resp.StatusCode)
+	}
+
+	var state zeroState
+	if err := json.NewDecoder(resp.Body).Decode(&state); err != nil {
+		return Topology{}, fmt.Errorf("invalid /state response from zero %s: %w", zero, err)
+	}
+
+	var topology Topology
+	for _, group := range state.Groups {
+		for _, member := range group.Members {
+			if member.AmDead || member.Addr == "" {
+				continue
+			}
+			topology.Alphas = append(topology.Alphas, Alpha{Addr: member.Addr, Group: member.GroupID, Leader: member.Leader})
+		}
+	}
+	sort.Slice(topology.Alphas, func(i, j int) bool { return topology.Alphas[i].Addr < topology.Alphas[j].Addr })
+	return topology, nil
+}
+
+// Equal reports whether t and other list the same alphas in the same roles.
+func (t Topology) Equal(other Topology) bool {
+	if len(t.Alphas) != len(other.Alphas) {
+		return false
+	}
+	for i := range t.Alphas {
+		if t.Alphas[i] != other.Alphas[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// offset returns the port offset of the alpha from its internal address.
+func (a Alpha) offset() (string, int, error) {
+	host, portStr, err := net.SplitHostPort(a.Addr)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid alpha address %q: %w", a.Addr, err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid port in alpha address %q: %w", a.Addr, err)
+	}
+	return host, port - internalPort, nil
+}
+
+// GRPCAddr returns the address dgo clients connect to.
+func (a Alpha) GRPCAddr() (string, error) {
+	host, offset, err := a.offset()
+	if err != nil {
+		return "", err
+	}
+	return net.JoinHostPort(host, strconv.Itoa(grpcPort+offset)), nil
+}
+
+// HTTPAddr returns the address of the alpha's HTTP listener.
+func (a Alpha) HTTPAddr() (string, error) {
+	host, offset, err := a.offset()
+	if err != nil {
+		return "", err
+	}
+	return net.JoinHostPort(host, strconv.Itoa(httpPort+offset)), nil
+}
+
+// Apply returns cfg with the discovered alphas as dgraph_endpoints and the
+// discovered groups added to the purpose groups: "leaders", "followers" and
+// "group-<id>", unless a group with that name is configured. Purposes listed
+// in the discovery config are pointed at their discovered group. The http
+// address, weight and tls set for an alpha in dgraph_endpoints are kept;
+// the HTTP address of other alphas is derived from Zero's.
+func Apply(cfg config.Config, topology Topology) config.Config {
+	listed := make(map[string]config.Endpoint, len(cfg.DgraphEndpoints))
+	for _, ep := range cfg.DgraphEndpoints {
+		listed[ep.Addr] = ep
+	}
+
+	discovered := make(map[string][]config.Endpoint)
+	var all []config.Endpoint
+	for _, alpha := range topology.Alphas {
+		addr, err := alpha.GRPCAddr()
+		if err != nil {
+			continue
+		}
+		known := listed[addr]
+		httpAddr := known.HTTP
+		if httpAddr == "" {
+			if httpAddr, err = alpha.HTTPAddr(); err != nil {
+				continue
+			}
+		}
+		ep := config.Endpoint{Addr: addr, HTTP: httpAddr, TLS: known.TLS, Weight: known.Weight}
+		all = append(all, ep)
+
+		role := "followers"
+		if alpha.Leader {
+			role = "leaders"
+		}
+		discovered[role] = append(discovered[role], ep)
+		group := fmt.Sprintf("group-%d", alpha.Group)
+		discovered[group] = append(discovered[group], ep)
+	}
+
+	cfg.DgraphEndpoints = all
+	groups := make(map[string][]config.Endpoint, len(cfg.Groups)+len(discovered))
+	for name, eps := range cfg.Groups {
+		groups[name] = eps
+	}
+	for name, eps := range discovered {
+		if _, configured := groups[name]; !configured {
+			groups[name] = eps
+		}
+	}
+	for purpose, name := range cfg.Discovery.Purposes {
+		if eps, ok := discovered[name]; ok {
+			groups[purpose] = eps
+		}
+	}
+	cfg.Groups = groups
+	return cfg
+}
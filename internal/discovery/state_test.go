@@ -0,0 +1,127 @@
+package discovery
+
+import (
+	"context"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/OpenDgraph/Otter/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+const stateJSON = `{
+  "counter": "12",
+  "groups": {
+    "1": {
+      "members": {
+        "1": {"id": "1", "groupId": 1, "addr": "alpha1:7080", "leader": true},
+        "2": {"id": "2", "groupId": 1, "addr": "alpha2:7081"},
+        "4": {"id": "4", "groupId": 1, "addr": "alpha4:7080", "amDead": true}
+      }
+    },
+    "2": {
+      "members": {
+        "3": {"id": "3", "groupId": 2, "addr": "alpha3:7082", "leader": true}
+      }
+    }
+  },
+  "zeros": {"1": {"id": "1", "addr": "zero1:5080", "leader": true}}
+}`
+
+func newZero(t *testing.T, body *string) string {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/state", r.URL.Path)
+		w.Write([]byte(*body))
+	}))
+	t.Cleanup(srv.Close)
+	return strings.TrimPrefix(srv.URL, "http://")
+}
+
+func TestFetchState(t *testing.T) {
+	body := stateJSON
+	topology, err := Fetch(context.Background(), http.DefaultClient, "http", newZero(t, &body))
+	require.NoError(t, err)
+	require.Equal(t, []Alpha{
+		{Addr: "alpha1:7080", Group: 1, Leader: true},
+		{Addr: "alpha2:7081", Group: 1},
+		{Addr: "alpha3:7082", Group: 2, Leader: true},
+	}, topology.Alphas)
+
+	grpcAddr, err := topology.Alphas[1].GRPCAddr()
+	require.NoError(t, err)
+	require.Equal(t, "alpha2:9081", grpcAddr)
+	httpAddr, err := topology.Alphas[2].HTTPAddr()
+	require.NoError(t, err)
+	require.Equal(t, "alpha3:8082", httpAddr)
+}
+
+func TestApplyTopology(t *testing.T) {
+	topology := Topology{Alphas: []Alpha{
+		{Addr: "alpha1:7080", Group: 1, Leader: true},
+		{Addr: "alpha2:7081", Group: 1},
+	}}
+	cfg := Apply(config.Config{
+		DgraphEndpoints: []config.Endpoint{{Addr: "alpha2:9081", HTTP: "localhost:18081", Weight: 3}},
+		Groups: map[string][]config.Endpoint{
+			"leaders": config.EndpointsFromAddrs("pinned:9080"),
+			"query":   config.EndpointsFromAddrs("old:9080"),
+		},
+		Discovery: config.DiscoveryConfig{Purposes: map[string]string{"query": "followers"}},
+	}, topology)
+
+	require.Equal(t, []config.Endpoint{
+		{Addr: "alpha1:9080", HTTP: "alpha1:8080"},
+		{Addr: "alpha2:9081", HTTP: "localhost:18081", Weight: 3},
+	}, cfg.DgraphEndpoints, "a configured http address wins over the derived one")
+	require.Equal(t, config.EndpointsFromAddrs("pinned:9080"), cfg.Groups["leaders"], "configured groups win")
+	require.Equal(t, []config.Endpoint{{Addr: "alpha2:9081", HTTP: "localhost:18081", Weight: 3}}, cfg.Groups["followers"])
+	require.Equal(t, cfg.Groups["followers"], cfg.Groups["query"])
+	require.Len(t, cfg.Groups["group-1"], 2)
+}
+
+func TestWatcherReportsChanges(t *testing.T) {
+	body := stateJSON
+	var got []Topology
+	w, err := NewWatcher(config.DiscoveryConfig{Zeros: []string{"127.0.0.1:1", newZero(t, &body)}}, func(topology Topology) {
+		got = append(got, topology)
+	})
+	require.NoError(t, err)
+
+	initial, err := w.Fetch(context.Background())
+	require.NoError(t, err, "an unreachable zero must not hide the next one")
+	w.last = initial
+
+	w.poll()
+	require.Empty(t, got)
+
+	body = strings.Replace(stateJSON, `"leader": true}`, `"leader": false}`, 1)
+	w.poll()
+	require.Len(t, got, 1)
+	require.False(t, got[0].Alphas[0].Leader)
+}
+
+func TestWatcherOverTLS(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(stateJSON))
+	}))
+	t.Cleanup(srv.Close)
+	caFile := filepath.Join(t.TempDir(), "ca.crt")
+	require.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}), 0o600))
+
+	zero := strings.TrimPrefix(srv.URL, "https://")
+	w, err := NewWatcher(config.DiscoveryConfig{Zeros: []string{zero}, TLS: config.ClientTLS{Enabled: true, CAFile: caFile}}, nil)
+	require.NoError(t, err)
+	topology, err := w.Fetch(context.Background())
+	require.NoError(t, err)
+	require.Len(t, topology.Alphas, 3)
+
+	w, err = NewWatcher(config.DiscoveryConfig{Zeros: []string{zero}}, nil)
+	require.NoError(t, err)
+	_, err = w.Fetch(context.Background())
+	require.Error(t, err, "a zero serving TLS is not reached in plaintext")
+}
@@ -0,0 +1,100 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/OpenDgraph/Otter/internal/config"
+	"github.com/OpenDgraph/Otter/internal/tlsconfig"
+)
+
+// Watcher polls Zero every Interval and calls onChange whenever the set of
+// live alphas or their roles change.
+type Watcher struct {
+	cfg      config.DiscoveryConfig
+	client   *http.Client
+	onChange func(Topology)
+
+	last     Topology
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func NewWatcher(cfg config.DiscoveryConfig, onChange func(Topology)) (*Watcher, error) {
+	cfg = cfg.WithDefaults()
+	client := &http.Client{Timeout: cfg.Timeout}
+	if cfg.TLS.Enabled {
+		tlsConfig, err := tlsconfig.Client(cfg.TLS)
+		if err != nil {
+			return nil, fmt.Errorf("configuring TLS for zeros: %w", err)
+		}
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = tlsConfig
+		client.Transport = transport
+	}
+	return &Watcher{
+		cfg:      cfg,
+		client:   client,
+		onChange: onChange,
+		stop:     make(chan struct{}),
+	}, nil
+}
+
+// Fetch asks each Zero in turn for the topology and returns the first answer.
+func (w *Watcher) Fetch(ctx context.Context) (Topology, error) {
+	var errs []error
+	for _, zero := range w.cfg.Zeros {
+		topology, err := Fetch(ctx, w.client, w.cfg.HTTPScheme(), zero)
+		if err == nil {
+			return topology, nil
+		}
+		errs = append(errs, err)
+	}
+	return Topology{}, errors.Join(errs...)
+}
+
+// Start polls in the background, starting from initial, the topology the
+// caller already applied.
+func (w *Watcher) Start(initial Topology) {
+	w.last = initial
+	go func() {
+		ticker := time.NewTicker(w.cfg.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-w.stop:
+				return
+			case <-ticker.C:
+				w.poll()
+			}
+		}
+	}()
+	log.Printf("| Discovery started (zeros %v every %s)", w.cfg.Zeros, w.cfg.Interval)
+}
+
+func (w *Watcher) Stop() {
+	w.stopOnce.Do(func() { close(w.stop) })
+}
+
+func (w *Watcher) poll() {
+	ctx, cancel := context.WithTimeout(context.Background(), w.cfg.Timeout)
+	defer cancel()
+
+	topology, err := w.Fetch(ctx)
+	if err != nil {
+		// Keep serving with the last known alphas.
+		log.Printf("Warning: Discovery failed, keeping %d known alphas: %v", len(w.last.Alphas), err)
+		return
+	}
+	if len(topology.Alphas) == 0 || topology.Equal(w.last) {
+		return
+	}
+	log.Printf("| Cluster topology changed: %d -> %d alphas", len(w.last.Alphas), len(topology.Alphas))
+	w.last = topology
+	w.onChange(topology)
+}
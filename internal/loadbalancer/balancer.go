@@ -3,48 +3,81 @@ package loadbalancer
 import (
 	"fmt"
 	"log"
-	"strconv"
-	"strings"
+	"net/url"
 	"sync"
 
 	"github.com/OpenDgraph/Otter/internal/config"
 )
 
 type EndpointInfo struct {
-	Endpoint string
-	Offset   int
+	Endpoint string // gRPC address
+	HTTP     string // HTTP address
+	TLS      config.ClientTLS
+	Weight   int
+
+	group    string   // purpose group that picked the endpoint, if any
+	observer Observer // balancer outcomes of calls to the endpoint go to
+}
+
+// Group returns the purpose group that picked the endpoint, empty when a
+// simple balancer did.
+func (e EndpointInfo) Group() string {
+	return e.group
+}
+
+// WithObserver returns a copy of e whose outcomes go to o, the balancer that
+// handed it out, even once that balancer has been replaced.
+func (e EndpointInfo) WithObserver(o Observer) EndpointInfo {
+	e.observer = o
+	return e
+}
+
+// Observer returns the balancer outcomes of calls to e go to, nil when none
+// was set.
+func (e EndpointInfo) Observer() Observer {
+	return e.observer
+}
+
+// HTTPURL returns the base URL of the endpoint's HTTP listener.
+func (e EndpointInfo) HTTPURL() *url.URL {
+	scheme := "http"
+	if e.TLS.Enabled {
+		scheme = "https"
+	}
+	return &url.URL{Scheme: scheme, Host: e.HTTP}
 }
 
 type Balancer interface {
 	Next() EndpointInfo
 }
 
-type RoundRobinBalancer struct {
-	nodes []EndpointInfo
-	next  int
-	mu    sync.Mutex
+// Stopper is implemented by balancers that run background work, such as
+// health checks, which must be stopped once the balancer is replaced.
+type Stopper interface {
+	Stop()
 }
 
-func NewRoundRobinBalancer(endpoints []string) *RoundRobinBalancer {
-	nodes := make([]EndpointInfo, 0, len(endpoints))
+// RoundRobinBalancer is a smooth weighted round-robin (as in nginx): every
+// pick, each available node gains its weight and the richest node is chosen
+// and pays back the total. With equal weights it is plain round-robin; with
+// weights 3:1 it yields a,a,b,a rather than a,a,a,b.
+type RoundRobinBalancer struct {
+	pool
+	current map[string]int
+	mu      sync.Mutex
+}
 
-	for _, ep := range endpoints {
-		offset, err := inferPort(ep)
-		if err != nil {
-			log.Printf("Warning: Ignoring endpoint '%s' in balancer: %v", ep, err)
-			continue
-		}
-		nodes = append(nodes, EndpointInfo{Endpoint: ep, Offset: offset})
-		log.Printf("Info: Endpoint '%s' added to balancer with offset %d", ep, offset)
-	}
+var _ Observer = (*RoundRobinBalancer)(nil)
 
+func NewRoundRobinBalancer(endpoints []config.Endpoint) *RoundRobinBalancer {
+	nodes := parseEndpoints(endpoints)
 	if len(nodes) == 0 {
 		log.Printf("Warning: No valid endpoint was added to RoundRobinBalancer.")
 	}
 
 	return &RoundRobinBalancer{
-		nodes: nodes,
-		next:  0,
+		pool:    pool{nodes: nodes},
+		current: make(map[string]int, len(nodes)),
 	}
 }
 
@@ -57,47 +90,83 @@ func (b *RoundRobinBalancer) Next() EndpointInfo {
 		return EndpointInfo{}
 	}
 
-	node := b.nodes[b.next]
-	b.next = (b.next + 1) % len(b.nodes)
-	return node
-}
-
-func inferPort(endpoint string) (int, error) {
-	endpoint = strings.TrimPrefix(endpoint, "http://")
-	endpoint = strings.TrimPrefix(endpoint, "https://")
+	best := -1
+	total := 0
+	for i, node := range b.nodes {
+		if !b.available(node) {
+			continue
+		}
+		b.current[node.Endpoint] += node.Weight
+		total += node.Weight
+		if best == -1 || b.current[node.Endpoint] > b.current[b.nodes[best].Endpoint] {
+			best = i
+		}
+	}
 
-	lastColon := strings.LastIndex(endpoint, ":")
-	if lastColon == -1 {
-		return 0, fmt.Errorf("port not found in endpoint: %s", endpoint)
+	if best == -1 {
+		log.Printf("Warning: All %d endpoints of RoundRobinBalancer are unhealthy.", len(b.nodes))
+		return EndpointInfo{}
 	}
 
-	portStr := endpoint[lastColon+1:]
+	node := b.nodes[best]
+	b.current[node.Endpoint] -= total
+	return node
+}
 
-	port, err := strconv.Atoi(portStr)
-	if err != nil {
-		return 0, fmt.Errorf("invalid port '%s' in endpoint '%s': %w", portStr, endpoint, err)
-	}
+// NewHealthyRoundRobinBalancer returns a round-robin balancer that skips the
+// endpoints the checker currently reports as unhealthy.
+func NewHealthyRoundRobinBalancer(endpoints []config.Endpoint, checker *HealthChecker) *RoundRobinBalancer {
+	balancer := NewRoundRobinBalancer(endpoints)
+	balancer.useHealthChecker(checker)
+	return balancer
+}
 
-	offset := port - 9080
-	return offset, nil
+func (b *RoundRobinBalancer) Observe(node EndpointInfo, outcome Outcome) {
+	b.report(node, outcome)
 }
 
 func NewBalancer(Config config.Config) (Balancer, error) {
 	endpoints := Config.DgraphEndpoints
 	balancerType := Config.BalancerType
 
+	log.Printf("| Running %s", balancerType)
+	balancer, err := newPoolBalancer(balancerType, endpoints, Config)
+	if err != nil {
+		return nil, err
+	}
+
+	base := balancer.base()
+	if len(base.nodes) == 0 && len(endpoints) > 0 {
+		return nil, fmt.Errorf("no valid endpoint could be processed for %s balancer", balancerType)
+	}
+
+	if balancerType == "round-robin-healthy" || Config.HealthCheck.Enabled {
+		checker := NewHealthChecker(Config.HealthCheck, base.nodes)
+		checker.Start()
+		base.useHealthChecker(checker)
+	}
+
+	if Config.Outlier.Enabled {
+		log.Printf("| Passive outlier ejection enabled")
+		base.useOutlierDetector(NewOutlierDetector(Config.Outlier))
+	}
+	return balancer, nil
+}
+
+// newPoolBalancer builds a single-pool strategy by name. It is shared by the
+// simple balancer and by every group of the purposeful one.
+func newPoolBalancer(balancerType string, endpoints []config.Endpoint, Config config.Config) (poolBalancer, error) {
 	switch balancerType {
-	case "round-robin":
-		log.Printf("| Running round-robin")
-		balancer := NewRoundRobinBalancer(endpoints)
-		if len(balancer.nodes) == 0 && len(endpoints) > 0 {
-			return nil, fmt.Errorf("no valid endpoint could be processed for round-robin balancer")
-		}
-		return balancer, nil
-	case "round-robin-healthy":
-		// Implement a round-robin healthy-only balancer
-		// that checks the health of endpoints before returning them.
-		return nil, fmt.Errorf("round-robin-healthy balancer is not implemented yet")
+	case "round-robin", "round-robin-healthy":
+		return NewRoundRobinBalancer(endpoints), nil
+	case "least-inflight":
+		return NewLeastInflightBalancer(endpoints), nil
+	case "ewma":
+		return NewEWMABalancer(endpoints, Config.EWMA, false), nil
+	case "peak-ewma":
+		return NewEWMABalancer(endpoints, Config.EWMA, true), nil
+	case "consistent-hash":
+		return NewConsistentHashBalancer(endpoints, Config.ConsistentHash), nil
 	default:
 		return nil, fmt.Errorf("unknown balancer type: %s", balancerType)
 	}
@@ -0,0 +1,52 @@
+package loadbalancer
+
+import (
+	"testing"
+
+	"github.com/OpenDgraph/Otter/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoundRobinWithoutWeights(t *testing.T) {
+	balancer := NewRoundRobinBalancer(config.EndpointsFromAddrs("localhost:9080", "localhost:9081", "localhost:9082"))
+
+	var got []string
+	for i := 0; i < 6; i++ {
+		got = append(got, balancer.Next().Endpoint)
+	}
+	require.Equal(t, []string{
+		"localhost:9080", "localhost:9081", "localhost:9082",
+		"localhost:9080", "localhost:9081", "localhost:9082",
+	}, got)
+}
+
+func TestSmoothWeightedRoundRobin(t *testing.T) {
+	balancer := NewRoundRobinBalancer([]config.Endpoint{
+		{Addr: "localhost:9080", Weight: 3},
+		{Addr: "localhost:9081"},
+	})
+
+	var got []string
+	for i := 0; i < 8; i++ {
+		got = append(got, balancer.Next().Endpoint)
+	}
+	// Heavy node is interleaved rather than picked in a burst.
+	require.Equal(t, []string{
+		"localhost:9080", "localhost:9080", "localhost:9081", "localhost:9080",
+		"localhost:9080", "localhost:9080", "localhost:9081", "localhost:9080",
+	}, got)
+}
+
+func TestLeastInflightHonoursWeights(t *testing.T) {
+	balancer := NewLeastInflightBalancer([]config.Endpoint{
+		{Addr: "localhost:9080", Weight: 2},
+		{Addr: "localhost:9081"},
+	})
+
+	counts := map[string]int{}
+	for i := 0; i < 3; i++ {
+		counts[balancer.Next().Endpoint]++
+	}
+	require.Equal(t, 2, counts["localhost:9080"])
+	require.Equal(t, 1, counts["localhost:9081"])
+}
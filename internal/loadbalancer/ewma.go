@@ -0,0 +1,169 @@
+package loadbalancer
+
+import (
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/OpenDgraph/Otter/internal/config"
+)
+
+type ewmaState struct {
+	cost     float64 // smoothed latency in nanoseconds
+	stamp    time.Time
+	inflight int
+	observed bool
+}
+
+// EWMABalancer keeps an exponentially weighted moving average of every
+// endpoint's latency and picks the one with the lowest expected cost,
+// cost being the average multiplied by the calls already in progress.
+// Costs are divided by the endpoint weight.
+// In peak mode a sample above the average replaces it immediately, so a
+// sudden slowdown is noticed on the first slow call and forgotten slowly.
+type EWMABalancer struct {
+	pool
+	cfg    config.EWMAConfig
+	peak   bool
+	states map[string]*ewmaState
+	next   int
+	mu     sync.Mutex
+	now    func() time.Time
+}
+
+var _ Observer = (*EWMABalancer)(nil)
+
+func NewEWMABalancer(endpoints []config.Endpoint, cfg config.EWMAConfig, peak bool) *EWMABalancer {
+	nodes := parseEndpoints(endpoints)
+	if len(nodes) == 0 {
+		log.Printf("Warning: No valid endpoint was added to EWMABalancer.")
+	}
+
+	states := make(map[string]*ewmaState, len(nodes))
+	for _, node := range nodes {
+		states[node.Endpoint] = &ewmaState{}
+	}
+
+	return &EWMABalancer{
+		pool:   pool{nodes: nodes},
+		cfg:    cfg.WithDefaults(),
+		peak:   peak,
+		states: states,
+		now:    time.Now,
+	}
+}
+
+func (b *EWMABalancer) Next() EndpointInfo {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.nodes) == 0 {
+		log.Printf("Warning: Attempt to call Next() on an EWMABalancer with no valid nodes.")
+		return EndpointInfo{}
+	}
+
+	now := b.now()
+	best := -1
+	bestCost := math.Inf(1)
+	for i := range b.nodes {
+		idx := (b.next + i) % len(b.nodes)
+		node := b.nodes[idx]
+		if !b.available(node) {
+			continue
+		}
+		if cost := b.score(b.states[node.Endpoint], now) / float64(node.Weight); cost < bestCost {
+			best, bestCost = idx, cost
+		}
+	}
+	b.next = (b.next + 1) % len(b.nodes)
+
+	if best == -1 {
+		log.Printf("Warning: All %d endpoints of EWMABalancer are unhealthy.", len(b.nodes))
+		return EndpointInfo{}
+	}
+
+	node := b.nodes[best]
+	b.states[node.Endpoint].inflight++
+	return node
+}
+
+// score returns the expected cost of sending one more call to the endpoint.
+// Endpoints never observed score zero so they get measured first.
+func (b *EWMABalancer) score(state *ewmaState, now time.Time) float64 {
+	if !state.observed {
+		return 0
+	}
+	return b.decayed(state, now) * float64(state.inflight+1)
+}
+
+// decayed returns the average as seen at now, pulled towards zero for the
+// time that passed without samples so idle endpoints get retried eventually.
+func (b *EWMABalancer) decayed(state *ewmaState, now time.Time) float64 {
+	elapsed := now.Sub(state.stamp)
+	if elapsed <= 0 {
+		return state.cost
+	}
+	return state.cost * math.Exp(-float64(elapsed)/float64(b.cfg.Decay))
+}
+
+func (b *EWMABalancer) Observe(node EndpointInfo, outcome Outcome) {
+	b.mu.Lock()
+	if state, ok := b.states[node.Endpoint]; ok {
+		if state.inflight > 0 {
+			state.inflight--
+		}
+		if !outcome.Refused {
+			b.update(state, b.sample(outcome))
+		}
+	}
+	b.mu.Unlock()
+
+	b.report(node, outcome)
+}
+
+// sample turns an outcome into a latency sample. The wall-clock time covers
+// the network and queueing; the latency Dgraph reports is used when larger.
+// Backend failures count as at least FailurePenalty so a fast-failing alpha
+// does not look like the fastest one.
+func (b *EWMABalancer) sample(outcome Outcome) float64 {
+	sample := outcome.Elapsed
+	if outcome.ServerLatency > sample {
+		sample = outcome.ServerLatency
+	}
+	if IsBackendFailure(outcome.Err) && sample < b.cfg.FailurePenalty {
+		sample = b.cfg.FailurePenalty
+	}
+	return float64(sample)
+}
+
+func (b *EWMABalancer) update(state *ewmaState, sample float64) {
+	now := b.now()
+	if !state.observed {
+		state.cost, state.stamp, state.observed = sample, now, true
+		return
+	}
+
+	if b.peak && sample > state.cost {
+		state.cost = sample
+	} else {
+		elapsed := now.Sub(state.stamp)
+		if elapsed < 0 {
+			elapsed = 0
+		}
+		w := math.Exp(-float64(elapsed) / float64(b.cfg.Decay))
+		state.cost = state.cost*w + sample*(1-w)
+	}
+	state.stamp = now
+}
+
+// Latency returns the current moving average for endpoint.
+func (b *EWMABalancer) Latency(endpoint string) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	state, ok := b.states[endpoint]
+	if !ok || !state.observed {
+		return 0
+	}
+	return time.Duration(state.cost)
+}
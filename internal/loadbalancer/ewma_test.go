@@ -0,0 +1,75 @@
+package loadbalancer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/OpenDgraph/Otter/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestEWMA(peak bool) (*EWMABalancer, *time.Time) {
+	now := time.Unix(1000, 0)
+	b := NewEWMABalancer(config.EndpointsFromAddrs("localhost:9080", "localhost:9081"), config.EWMAConfig{Decay: 10 * time.Second}, peak)
+	b.now = func() time.Time { return now }
+	return b, &now
+}
+
+func TestEWMAPrefersFastestEndpoint(t *testing.T) {
+	b, _ := newTestEWMA(false)
+
+	fast := EndpointInfo{Endpoint: "localhost:9080"}
+	slow := EndpointInfo{Endpoint: "localhost:9081"}
+	b.Next()
+	b.Next()
+	b.Observe(fast, Outcome{Elapsed: 5 * time.Millisecond})
+	b.Observe(slow, Outcome{Elapsed: 200 * time.Millisecond, ServerLatency: 150 * time.Millisecond})
+
+	for i := 0; i < 5; i++ {
+		node := b.Next()
+		require.Equal(t, fast.Endpoint, node.Endpoint)
+		b.Observe(node, Outcome{Elapsed: 5 * time.Millisecond})
+	}
+}
+
+func TestEWMAUsesServerLatencyWhenLarger(t *testing.T) {
+	b, _ := newTestEWMA(false)
+	node := b.Next()
+	b.Observe(node, Outcome{Elapsed: time.Millisecond, ServerLatency: 40 * time.Millisecond})
+	require.Equal(t, 40*time.Millisecond, b.Latency(node.Endpoint))
+}
+
+func TestPeakEWMAReactsToSpikes(t *testing.T) {
+	smooth, now := newTestEWMA(false)
+	peak, _ := newTestEWMA(true)
+	peak.now = smooth.now
+
+	for _, b := range []*EWMABalancer{smooth, peak} {
+		node := EndpointInfo{Endpoint: "localhost:9080"}
+		b.Observe(node, Outcome{Elapsed: 10 * time.Millisecond})
+	}
+	*now = now.Add(time.Second)
+	for _, b := range []*EWMABalancer{smooth, peak} {
+		b.Observe(EndpointInfo{Endpoint: "localhost:9080"}, Outcome{Elapsed: time.Second})
+	}
+
+	require.Equal(t, time.Second, peak.Latency("localhost:9080"))
+	require.Less(t, smooth.Latency("localhost:9080"), 200*time.Millisecond)
+}
+
+func TestPurposefulGroupsUseConfiguredStrategy(t *testing.T) {
+	b := NewPurposefulBalancer(config.Config{
+		GroupBalancer: "peak-ewma",
+		Groups: map[string][]config.Endpoint{
+			"query": config.EndpointsFromAddrs("localhost:9080", "localhost:9081"),
+		},
+	}).(*definedBalancer)
+
+	_, ok := b.groups["query"].(*EWMABalancer)
+	require.True(t, ok)
+
+	node, err := b.Next("query")
+	require.NoError(t, err)
+	b.Observe(node, Outcome{Elapsed: time.Millisecond})
+	require.Equal(t, time.Millisecond, b.groups["query"].(*EWMABalancer).Latency(node.Endpoint))
+}
@@ -0,0 +1,112 @@
+package loadbalancer
+
+import (
+	"hash/fnv"
+	"log"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/OpenDgraph/Otter/internal/config"
+)
+
+// KeyedBalancer is implemented by balancers that pick the endpoint from a
+// routing key instead of from their own state.
+type KeyedBalancer interface {
+	NextFor(key string) EndpointInfo
+}
+
+type ringPoint struct {
+	hash uint64
+	node int
+}
+
+// ConsistentHashBalancer maps routing keys onto a hash ring with Replicas
+// virtual points per unit of weight, so the same key keeps landing on the
+// same alpha and its caches stay warm. Unavailable nodes are skipped by
+// walking the ring clockwise: only their keys move, to the next node, and
+// they come back to them once the node recovers.
+type ConsistentHashBalancer struct {
+	pool
+	ring []ringPoint
+	next int
+	mu   sync.Mutex
+}
+
+var (
+	_ Observer      = (*ConsistentHashBalancer)(nil)
+	_ KeyedBalancer = (*ConsistentHashBalancer)(nil)
+)
+
+func NewConsistentHashBalancer(endpoints []config.Endpoint, cfg config.ConsistentHashConfig) *ConsistentHashBalancer {
+	nodes := parseEndpoints(endpoints)
+	if len(nodes) == 0 {
+		log.Printf("Warning: No valid endpoint was added to ConsistentHashBalancer.")
+	}
+	cfg = cfg.WithDefaults()
+
+	var ring []ringPoint
+	for i, node := range nodes {
+		for r := 0; r < cfg.Replicas*node.Weight; r++ {
+			ring = append(ring, ringPoint{hash: hashKey(node.Endpoint + "#" + strconv.Itoa(r)), node: i})
+		}
+	}
+	sort.Slice(ring, func(i, j int) bool { return ring[i].hash < ring[j].hash })
+
+	return &ConsistentHashBalancer{
+		pool: pool{nodes: nodes},
+		ring: ring,
+	}
+}
+
+// Next is used when a request carries no routing key: it falls back to
+// plain round-robin over the available nodes.
+func (b *ConsistentHashBalancer) Next() EndpointInfo {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for range b.nodes {
+		node := b.nodes[b.next]
+		b.next = (b.next + 1) % len(b.nodes)
+		if b.available(node) {
+			return node
+		}
+	}
+	log.Printf("Warning: No available endpoint in ConsistentHashBalancer.")
+	return EndpointInfo{}
+}
+
+func (b *ConsistentHashBalancer) NextFor(key string) EndpointInfo {
+	if key == "" || len(b.ring) == 0 {
+		return b.Next()
+	}
+
+	h := hashKey(key)
+	start := sort.Search(len(b.ring), func(i int) bool { return b.ring[i].hash >= h })
+	for i := 0; i < len(b.ring); i++ {
+		node := b.nodes[b.ring[(start+i)%len(b.ring)].node]
+		if b.available(node) {
+			return node
+		}
+	}
+	log.Printf("Warning: No available endpoint in ConsistentHashBalancer.")
+	return EndpointInfo{}
+}
+
+func (b *ConsistentHashBalancer) Observe(node EndpointInfo, outcome Outcome) {
+	b.report(node, outcome)
+}
+
+func hashKey(key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	// fnv alone clusters similar keys ("a#1", "a#2"); mix the bits so
+	// virtual nodes spread evenly over the ring.
+	x := h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
@@ -0,0 +1,61 @@
+package loadbalancer
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/OpenDgraph/Otter/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConsistentHashIsStable(t *testing.T) {
+	endpoints := config.EndpointsFromAddrs("localhost:9080", "localhost:9081", "localhost:9082")
+	a := NewConsistentHashBalancer(endpoints, config.ConsistentHashConfig{})
+	b := NewConsistentHashBalancer(endpoints, config.ConsistentHashConfig{})
+
+	for i := 0; i < 50; i++ {
+		key := fmt.Sprintf("eq(email)#%d", i)
+		require.Equal(t, a.NextFor(key).Endpoint, a.NextFor(key).Endpoint)
+		require.Equal(t, a.NextFor(key).Endpoint, b.NextFor(key).Endpoint, "ring must not depend on the instance")
+	}
+}
+
+func TestConsistentHashMovesOnlyKeysOfUnhealthyNode(t *testing.T) {
+	endpoints := config.EndpointsFromAddrs("localhost:9080", "localhost:9081", "localhost:9082")
+	balancer := NewConsistentHashBalancer(endpoints, config.ConsistentHashConfig{})
+
+	before := map[string]string{}
+	for i := 0; i < 300; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		before[key] = balancer.NextFor(key).Endpoint
+	}
+
+	down := "localhost:9081"
+	balancer.healthy = func(endpoint string) bool { return endpoint != down }
+
+	moved := 0
+	for key, was := range before {
+		now := balancer.NextFor(key).Endpoint
+		require.NotEqual(t, down, now)
+		if was != down {
+			require.Equal(t, was, now, "keys of healthy nodes must stay put")
+		} else {
+			moved++
+		}
+	}
+	require.Greater(t, moved, 50, "the down node should have owned roughly a third of the keys")
+	require.Less(t, moved, 150)
+}
+
+func TestConsistentHashWeights(t *testing.T) {
+	balancer := NewConsistentHashBalancer([]config.Endpoint{
+		{Addr: "localhost:9080", Weight: 3},
+		{Addr: "localhost:9081"},
+	}, config.ConsistentHashConfig{})
+
+	counts := map[string]int{}
+	for i := 0; i < 2000; i++ {
+		counts[balancer.NextFor(fmt.Sprintf("key-%d", i)).Endpoint]++
+	}
+	require.Greater(t, counts["localhost:9080"], 2*counts["localhost:9081"])
+}
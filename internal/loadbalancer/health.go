@@ -0,0 +1,244 @@
+package loadbalancer
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/OpenDgraph/Otter/internal/config"
+	"github.com/OpenDgraph/Otter/internal/tlsconfig"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// Prober checks a single endpoint once and returns nil when it is healthy.
+type Prober func(ctx context.Context, node EndpointInfo) error
+
+type healthState struct {
+	healthy   bool
+	failures  int
+	successes int
+	lastErr   error
+}
+
+// HealthChecker probes every endpoint in the background and keeps track of
+// which ones may receive traffic. A node is taken out of rotation after
+// FailureThreshold consecutive failed probes and put back after
+// SuccessThreshold consecutive successful ones.
+type HealthChecker struct {
+	cfg    config.HealthCheckConfig
+	nodes  []EndpointInfo
+	probe  Prober
+	states map[string]*healthState
+	mu     sync.RWMutex
+
+	conns   map[string]*grpc.ClientConn
+	clients map[string]*http.Client // of TLS endpoints
+	connsMu sync.Mutex
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func NewHealthChecker(cfg config.HealthCheckConfig, nodes []EndpointInfo) *HealthChecker {
+	h := &HealthChecker{
+		cfg:     cfg.WithDefaults(),
+		nodes:   nodes,
+		states:  make(map[string]*healthState, len(nodes)),
+		conns:   make(map[string]*grpc.ClientConn),
+		clients: make(map[string]*http.Client),
+		stop:    make(chan struct{}),
+	}
+	for _, node := range nodes {
+		// Nodes start healthy so the proxy can serve before the first round finishes.
+		h.states[node.Endpoint] = &healthState{healthy: true}
+	}
+
+	switch h.cfg.Mode {
+	case "grpc":
+		h.probe = h.probeGRPC
+	case "both":
+		h.probe = func(ctx context.Context, node EndpointInfo) error {
+			if err := h.probeHTTP(ctx, node); err != nil {
+				return err
+			}
+			return h.probeGRPC(ctx, node)
+		}
+	default:
+		h.probe = h.probeHTTP
+	}
+	return h
+}
+
+// Start runs a first probe round synchronously and then keeps probing every
+// Interval until Stop is called.
+func (h *HealthChecker) Start() {
+	h.probeAll()
+
+	go func() {
+		ticker := time.NewTicker(h.cfg.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-h.stop:
+				return
+			case <-ticker.C:
+				h.probeAll()
+			}
+		}
+	}()
+	log.Printf("| Health checker started (%s every %s) for %d endpoints", h.cfg.Mode, h.cfg.Interval, len(h.nodes))
+}
+
+func (h *HealthChecker) Stop() {
+	h.stopOnce.Do(func() {
+		close(h.stop)
+		h.connsMu.Lock()
+		defer h.connsMu.Unlock()
+		for ep, conn := range h.conns {
+			conn.Close()
+			delete(h.conns, ep)
+		}
+		for ep, client := range h.clients {
+			client.CloseIdleConnections()
+			delete(h.clients, ep)
+		}
+	})
+}
+
+// Healthy reports whether endpoint may receive traffic. Endpoints the checker
+// does not know about are considered healthy.
+func (h *HealthChecker) Healthy(endpoint string) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	state, ok := h.states[endpoint]
+	return !ok || state.healthy
+}
+
+func (h *HealthChecker) probeAll() {
+	var wg sync.WaitGroup
+	for _, node := range h.nodes {
+		wg.Add(1)
+		go func(node EndpointInfo) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Timeout)
+			defer cancel()
+			h.record(node.Endpoint, h.probe(ctx, node))
+		}(node)
+	}
+	wg.Wait()
+}
+
+func (h *HealthChecker) record(endpoint string, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	state, ok := h.states[endpoint]
+	if !ok {
+		return
+	}
+	state.lastErr = err
+
+	if err != nil {
+		state.successes = 0
+		state.failures++
+		if state.healthy && state.failures >= h.cfg.FailureThreshold {
+			state.healthy = false
+			log.Printf("Warning: Endpoint '%s' marked unhealthy after %d failed probes: %v", endpoint, state.failures, err)
+		}
+		return
+	}
+
+	state.failures = 0
+	state.successes++
+	if !state.healthy && state.successes >= h.cfg.SuccessThreshold {
+		state.healthy = true
+		log.Printf("Info: Endpoint '%s' marked healthy again after %d successful probes", endpoint, state.successes)
+	}
+}
+
+func (h *HealthChecker) probeHTTP(ctx context.Context, node EndpointInfo) error {
+	target := node.HTTPURL()
+	target.Path = h.cfg.Path
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		return err
+	}
+	client, err := h.httpClient(node)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("health endpoint returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (h *HealthChecker) probeGRPC(ctx context.Context, node EndpointInfo) error {
+	conn, err := h.grpcConn(node)
+	if err != nil {
+		return err
+	}
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		return err
+	}
+	if resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		return fmt.Errorf("grpc health status is %s", resp.GetStatus())
+	}
+	return nil
+}
+
+func (h *HealthChecker) grpcConn(node EndpointInfo) (*grpc.ClientConn, error) {
+	h.connsMu.Lock()
+	defer h.connsMu.Unlock()
+
+	if conn, ok := h.conns[node.Endpoint]; ok {
+		return conn, nil
+	}
+	tlsConfig, err := tlsconfig.Client(node.TLS)
+	if err != nil {
+		return nil, fmt.Errorf("could not create health connection to %s: %w", node.Endpoint, err)
+	}
+	creds := insecure.NewCredentials()
+	if tlsConfig != nil {
+		creds = credentials.NewTLS(tlsConfig)
+	}
+	conn, err := grpc.NewClient(node.Endpoint, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, fmt.Errorf("could not create health connection to %s: %w", node.Endpoint, err)
+	}
+	h.conns[node.Endpoint] = conn
+	return conn, nil
+}
+
+func (h *HealthChecker) httpClient(node EndpointInfo) (*http.Client, error) {
+	if !node.TLS.Enabled {
+		return http.DefaultClient, nil
+	}
+	h.connsMu.Lock()
+	defer h.connsMu.Unlock()
+
+	if client, ok := h.clients[node.Endpoint]; ok {
+		return client, nil
+	}
+	tlsConfig, err := tlsconfig.Client(node.TLS)
+	if err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	client := &http.Client{Transport: transport}
+	h.clients[node.Endpoint] = client
+	return client, nil
+}
@@ -0,0 +1,88 @@
+package loadbalancer
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/OpenDgraph/Otter/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeProbe struct {
+	mu   sync.Mutex
+	down map[string]bool
+}
+
+func (f *fakeProbe) set(endpoint string, down bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.down[endpoint] = down
+}
+
+func (f *fakeProbe) probe(_ context.Context, node EndpointInfo) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.down[node.Endpoint] {
+		return errors.New("down")
+	}
+	return nil
+}
+
+func newTestChecker(endpoints []string) (*HealthChecker, *fakeProbe) {
+	fake := &fakeProbe{down: map[string]bool{}}
+	cfg := config.HealthCheckConfig{FailureThreshold: 2, SuccessThreshold: 2}
+	checker := NewHealthChecker(cfg, NewRoundRobinBalancer(config.EndpointsFromAddrs(endpoints...)).nodes)
+	checker.probe = fake.probe
+	return checker, fake
+}
+
+func TestHealthCheckerThresholds(t *testing.T) {
+	checker, fake := newTestChecker([]string{"localhost:9080", "localhost:9081"})
+
+	fake.set("localhost:9081", true)
+	checker.probeAll()
+	require.True(t, checker.Healthy("localhost:9081"), "one failure must not eject the node")
+
+	checker.probeAll()
+	require.False(t, checker.Healthy("localhost:9081"))
+	require.True(t, checker.Healthy("localhost:9080"))
+
+	fake.set("localhost:9081", false)
+	checker.probeAll()
+	require.False(t, checker.Healthy("localhost:9081"), "one success must not restore the node")
+
+	checker.probeAll()
+	require.True(t, checker.Healthy("localhost:9081"))
+}
+
+func TestHealthyRoundRobinSkipsUnhealthy(t *testing.T) {
+	endpoints := []string{"localhost:9080", "localhost:9081", "localhost:9082"}
+	checker, fake := newTestChecker(endpoints)
+	balancer := NewHealthyRoundRobinBalancer(config.EndpointsFromAddrs(endpoints...), checker)
+
+	fake.set("localhost:9081", true)
+	checker.probeAll()
+	checker.probeAll()
+
+	for i := 0; i < 6; i++ {
+		require.NotEqual(t, "localhost:9081", balancer.Next().Endpoint)
+	}
+
+	for _, ep := range endpoints {
+		fake.set(ep, true)
+	}
+	checker.probeAll()
+	checker.probeAll()
+	require.Equal(t, "", balancer.Next().Endpoint)
+}
+
+func TestHTTPAddress(t *testing.T) {
+	nodes := parseEndpoints([]config.Endpoint{
+		{Addr: "dgraph-alpha2:9082"},
+		{Addr: "alpha-0.dgraph:443", HTTP: "alpha-0-http.dgraph:8443", TLS: config.ClientTLS{Enabled: true}},
+	})
+	require.Equal(t, "http://dgraph-alpha2:8082", nodes[0].HTTPURL().String())
+	require.Equal(t, "https://alpha-0-http.dgraph:8443", nodes[1].HTTPURL().String())
+}
@@ -0,0 +1,90 @@
+package loadbalancer
+
+import (
+	"log"
+	"sync"
+
+	"github.com/OpenDgraph/Otter/internal/config"
+)
+
+// LeastInflightBalancer sends every request to the available endpoint with
+// the fewest calls still in progress relative to its weight, so a slow alpha
+// stops receiving new work until it drains its queue. Every Next must be
+// paired with an Observe once the call finishes, otherwise the counters
+// never go down.
+type LeastInflightBalancer struct {
+	pool
+	inflight map[string]int
+	next     int
+	mu       sync.Mutex
+}
+
+var _ Observer = (*LeastInflightBalancer)(nil)
+
+func NewLeastInflightBalancer(endpoints []config.Endpoint) *LeastInflightBalancer {
+	nodes := parseEndpoints(endpoints)
+	if len(nodes) == 0 {
+		log.Printf("Warning: No valid endpoint was added to LeastInflightBalancer.")
+	}
+
+	return &LeastInflightBalancer{
+		pool:     pool{nodes: nodes},
+		inflight: make(map[string]int, len(nodes)),
+	}
+}
+
+func (b *LeastInflightBalancer) Next() EndpointInfo {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.nodes) == 0 {
+		log.Printf("Warning: Attempt to call Next() on a LeastInflightBalancer with no valid nodes.")
+		return EndpointInfo{}
+	}
+
+	// Start scanning at a rotating offset so ties are spread evenly.
+	best := -1
+	for i := range b.nodes {
+		idx := (b.next + i) % len(b.nodes)
+		node := b.nodes[idx]
+		if !b.available(node) {
+			continue
+		}
+		if best == -1 || b.less(node, b.nodes[best]) {
+			best = idx
+		}
+	}
+	b.next = (b.next + 1) % len(b.nodes)
+
+	if best == -1 {
+		log.Printf("Warning: All %d endpoints of LeastInflightBalancer are unhealthy.", len(b.nodes))
+		return EndpointInfo{}
+	}
+
+	node := b.nodes[best]
+	b.inflight[node.Endpoint]++
+	return node
+}
+
+// less reports whether a is less loaded than c, comparing inflight/weight
+// without dividing.
+func (b *LeastInflightBalancer) less(a, c EndpointInfo) bool {
+	return b.inflight[a.Endpoint]*c.Weight < b.inflight[c.Endpoint]*a.Weight
+}
+
+func (b *LeastInflightBalancer) Observe(node EndpointInfo, outcome Outcome) {
+	b.mu.Lock()
+	if b.inflight[node.Endpoint] > 0 {
+		b.inflight[node.Endpoint]--
+	}
+	b.mu.Unlock()
+
+	b.report(node, outcome)
+}
+
+// Inflight returns the number of calls currently in progress on endpoint.
+func (b *LeastInflightBalancer) Inflight(endpoint string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.inflight[endpoint]
+}
@@ -0,0 +1,37 @@
+package loadbalancer
+
+import (
+	"testing"
+
+	"github.com/OpenDgraph/Otter/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLeastInflightPicksIdlestEndpoint(t *testing.T) {
+	balancer := NewLeastInflightBalancer(config.EndpointsFromAddrs("localhost:9080", "localhost:9081"))
+
+	first := balancer.Next()
+	second := balancer.Next()
+	require.NotEqual(t, first.Endpoint, second.Endpoint, "ties must be spread across endpoints")
+
+	// first finishes, second is still busy: the next call goes to first.
+	balancer.Observe(first, Outcome{})
+	require.Equal(t, first.Endpoint, balancer.Next().Endpoint)
+	require.Equal(t, 1, balancer.Inflight(first.Endpoint))
+	require.Equal(t, 1, balancer.Inflight(second.Endpoint))
+
+	// A slow endpoint accumulates calls and stops being picked.
+	balancer.Observe(first, Outcome{})
+	for i := 0; i < 3; i++ {
+		node := balancer.Next()
+		require.Equal(t, first.Endpoint, node.Endpoint)
+		balancer.Observe(node, Outcome{})
+	}
+}
+
+func TestLeastInflightObserveNeverGoesNegative(t *testing.T) {
+	balancer := NewLeastInflightBalancer(config.EndpointsFromAddrs("localhost:9080"))
+	node := EndpointInfo{Endpoint: "localhost:9080"}
+	balancer.Observe(node, Outcome{})
+	require.Equal(t, 0, balancer.Inflight(node.Endpoint))
+}
@@ -0,0 +1,136 @@
+package loadbalancer
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/OpenDgraph/Otter/internal/config"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Outcome describes how a single backend call went.
+type Outcome struct {
+	Err           error
+	Elapsed       time.Duration // wall-clock time seen by Otter
+	ServerLatency time.Duration // total latency reported by Dgraph, if any
+	Refused       bool          // never made or abandoned by the client: only the pick is undone
+}
+
+// Observer is implemented by balancers that learn from the outcome of the
+// calls made to the endpoints they hand out.
+type Observer interface {
+	Observe(node EndpointInfo, outcome Outcome)
+}
+
+type outlierState struct {
+	consecutive  int
+	ejections    int
+	ejectedUntil time.Time
+}
+
+// OutlierDetector ejects an endpoint for a backoff period once it returns
+// ConsecutiveErrors Unavailable/deadline errors in a row. Every further
+// ejection doubles the backoff, up to MaxEjection; a successful call resets it.
+type OutlierDetector struct {
+	cfg    config.OutlierConfig
+	states map[string]*outlierState
+	mu     sync.Mutex
+	now    func() time.Time
+}
+
+func NewOutlierDetector(cfg config.OutlierConfig) *OutlierDetector {
+	return &OutlierDetector{
+		cfg:    cfg.WithDefaults(),
+		states: make(map[string]*outlierState),
+		now:    time.Now,
+	}
+}
+
+// Report records the result of a call made to endpoint.
+func (d *OutlierDetector) Report(endpoint string, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	state, ok := d.states[endpoint]
+	if !ok {
+		state = &outlierState{}
+		d.states[endpoint] = state
+	}
+
+	if !IsBackendFailure(err) {
+		state.consecutive = 0
+		if err == nil {
+			state.ejections = 0
+		}
+		return
+	}
+
+	state.consecutive++
+	if state.consecutive < d.cfg.ConsecutiveErrors {
+		return
+	}
+
+	backoff := d.cfg.BaseEjection << state.ejections
+	if backoff <= 0 || backoff > d.cfg.MaxEjection {
+		backoff = d.cfg.MaxEjection
+	}
+	state.ejectedUntil = d.now().Add(backoff)
+	state.ejections++
+	state.consecutive = 0
+	log.Printf("Warning: Endpoint '%s' ejected for %s after %d consecutive failures: %v", endpoint, backoff, d.cfg.ConsecutiveErrors, err)
+}
+
+// Available reports whether endpoint is outside its ejection period.
+func (d *OutlierDetector) Available(endpoint string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	state, ok := d.states[endpoint]
+	return !ok || !d.now().Before(state.ejectedUntil)
+}
+
+// IsBackendFailure tells apart errors caused by an unreachable or stuck
+// alpha from errors caused by the request itself (bad query, aborted txn...).
+func IsBackendFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		// Reverse-proxied HTTP calls fail with network errors instead of gRPC codes.
+		return true
+	}
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded:
+		return true
+	}
+	return false
+}
+
+// allHealthy combines several availability checks, ignoring nil ones.
+func allHealthy(checks ...func(endpoint string) bool) func(endpoint string) bool {
+	var active []func(string) bool
+	for _, check := range checks {
+		if check != nil {
+			active = append(active, check)
+		}
+	}
+	if len(active) == 0 {
+		return nil
+	}
+	return func(endpoint string) bool {
+		for _, check := range active {
+			if !check(endpoint) {
+				return false
+			}
+		}
+		return true
+	}
+}
@@ -0,0 +1,63 @@
+package loadbalancer
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/OpenDgraph/Otter/internal/config"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestOutlierDetectorEjectsAndBacksOff(t *testing.T) {
+	now := time.Unix(1000, 0)
+	d := NewOutlierDetector(config.OutlierConfig{ConsecutiveErrors: 2, BaseEjection: time.Second, MaxEjection: 3 * time.Second})
+	d.now = func() time.Time { return now }
+
+	unavailable := fmt.Errorf("error querying Dgraph: %w", status.Error(codes.Unavailable, "connection refused"))
+
+	d.Report("a", unavailable)
+	require.True(t, d.Available("a"))
+	d.Report("a", unavailable)
+	require.False(t, d.Available("a"))
+
+	now = now.Add(time.Second)
+	require.True(t, d.Available("a"))
+
+	// Second ejection doubles the backoff.
+	d.Report("a", unavailable)
+	d.Report("a", unavailable)
+	now = now.Add(time.Second)
+	require.False(t, d.Available("a"))
+	now = now.Add(time.Second)
+	require.True(t, d.Available("a"))
+
+	// A success resets the backoff.
+	d.Report("a", nil)
+	d.Report("a", unavailable)
+	d.Report("a", unavailable)
+	now = now.Add(time.Second)
+	require.True(t, d.Available("a"))
+}
+
+func TestOutlierDetectorIgnoresRequestErrors(t *testing.T) {
+	d := NewOutlierDetector(config.OutlierConfig{ConsecutiveErrors: 1})
+	d.Report("a", errors.New("while lexing query: unexpected EOF"))
+	d.Report("a", status.Error(codes.Aborted, "transaction has been aborted"))
+	require.True(t, d.Available("a"))
+}
+
+func TestRoundRobinSkipsEjectedEndpoints(t *testing.T) {
+	balancer := NewRoundRobinBalancer(config.EndpointsFromAddrs("localhost:9080", "localhost:9081"))
+	balancer.useOutlierDetector(NewOutlierDetector(config.OutlierConfig{ConsecutiveErrors: 1}))
+
+	ejected := EndpointInfo{Endpoint: "localhost:9081"}
+	balancer.Observe(ejected, Outcome{Err: status.Error(codes.DeadlineExceeded, "timeout")})
+
+	for i := 0; i < 4; i++ {
+		require.Equal(t, "localhost:9080", balancer.Next().Endpoint)
+	}
+}
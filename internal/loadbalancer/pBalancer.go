@@ -2,40 +2,172 @@ package loadbalancer
 
 import (
 	"fmt"
+	"log"
+	"sort"
+	"strings"
 
 	"github.com/OpenDgraph/Otter/internal/config"
 )
 
+// Names with a special meaning in fallback chains, unless a group with the
+// same name is configured.
+const (
+	DefaultGroup  = "default" // the plain dgraph_endpoints list
+	AnyGroup      = "any"     // every endpoint known to the balancer
+	WildcardChain = "*"       // fallback chain for purposes without their own
+)
+
 type definedBalancer struct {
-	groups map[string]*RoundRobinBalancer
+	groups    map[string]poolBalancer
+	fallbacks map[string][]string
 }
 
 type PurposefulBalancer interface {
 	Next(purpose string) (EndpointInfo, error)
+	HasPurpose(purpose string) bool
 	AllEndpoints() []string
 }
 
-var _ PurposefulBalancer = (*definedBalancer)(nil)
+// KeyedPurposefulBalancer is implemented by purposeful balancers whose
+// groups can route by key.
+type KeyedPurposefulBalancer interface {
+	NextFor(purpose, key string) (EndpointInfo, error)
+}
+
+var (
+	_ PurposefulBalancer      = (*definedBalancer)(nil)
+	_ KeyedPurposefulBalancer = (*definedBalancer)(nil)
+	_ Observer                = (*definedBalancer)(nil)
+	_ Stopper                 = (*definedBalancer)(nil)
+)
 
 func NewPurposefulBalancer(Config config.Config) PurposefulBalancer {
-	groups := Config.Groups
-	result := make(map[string]*RoundRobinBalancer)
+	groupType := Config.GroupBalancer
+	if groupType == "" {
+		groupType = "round-robin"
+	}
+
+	groups := make(map[string][]config.Endpoint, len(Config.Groups))
+	for purpose, eps := range Config.Groups {
+		groups[purpose] = eps
+	}
+	addFallbackGroups(groups, Config)
+
+	result := make(map[string]poolBalancer)
 	for purpose, eps := range groups {
 		fmt.Printf("Purpose: %s, Endpoints: %v\n", purpose, eps)
-		result[purpose] = NewRoundRobinBalancer(eps)
+		group, err := newPoolBalancer(groupType, eps, Config)
+		if err != nil {
+			log.Printf("Warning: %v for purpose %s. Falling back to round-robin.", err, purpose)
+			group = NewRoundRobinBalancer(eps)
+		}
+		result[purpose] = group
+	}
+	b := &definedBalancer{groups: result, fallbacks: Config.Fallbacks}
+
+	if groupType == "round-robin-healthy" || Config.HealthCheck.Enabled {
+		// One checker for every group, so an alpha shared by several
+		// purposes is probed once and leaves all its groups together.
+		seen := make(map[string]struct{})
+		var nodes []EndpointInfo
+		for _, group := range result {
+			for _, node := range group.base().nodes {
+				if _, exists := seen[node.Endpoint]; !exists {
+					nodes = append(nodes, node)
+					seen[node.Endpoint] = struct{}{}
+				}
+			}
+		}
+		checker := NewHealthChecker(Config.HealthCheck, nodes)
+		checker.Start()
+		for _, group := range result {
+			group.base().useHealthChecker(checker)
+		}
+	}
+
+	if Config.Outlier.Enabled {
+		// Shared as well: failures seen through one purpose eject the
+		// endpoint from every group it belongs to.
+		outliers := NewOutlierDetector(Config.Outlier)
+		for _, group := range result {
+			group.base().useOutlierDetector(outliers)
+		}
+	}
+	return b
+}
+
+// Observe hands the outcome to the group that picked the endpoint, which
+// forwards it to the shared outlier detector if there is one.
+func (b *definedBalancer) Observe(node EndpointInfo, outcome Outcome) {
+	if group, ok := b.groups[node.group]; ok {
+		group.Observe(node, outcome)
 	}
-	return &definedBalancer{groups: result}
 }
 
 func (b *definedBalancer) Next(purpose string) (EndpointInfo, error) {
+	return b.NextFor(purpose, "")
+}
+
+// NextFor picks from the purpose group by key when the group routes by key,
+// and falls back to the group's own order otherwise. When the group has no
+// available endpoint, the groups of its fallback chain are tried in order.
+func (b *definedBalancer) NextFor(purpose, key string) (EndpointInfo, error) {
+	node, err := b.nextFromGroup(purpose, key)
+	if err == nil {
+		return node, nil
+	}
+
+	chain, ok := b.fallbacks[purpose]
+	if !ok {
+		chain = b.fallbacks[WildcardChain]
+	}
+	errs := []string{err.Error()}
+	for _, fallback := range chain {
+		if fallback == purpose {
+			continue
+		}
+		node, err := b.nextFromGroup(fallback, key)
+		if err == nil {
+			log.Printf("| Purpose %s degraded to group %s: %s", purpose, fallback, errs[len(errs)-1])
+			return node, nil
+		}
+		errs = append(errs, err.Error())
+	}
+	return EndpointInfo{}, fmt.Errorf("%s", strings.Join(errs, "; "))
+}
+
+func (b *definedBalancer) nextFromGroup(purpose, key string) (EndpointInfo, error) {
 	group, ok := b.groups[purpose]
 	if !ok {
 		return EndpointInfo{}, fmt.Errorf("no endpoints defined for purpose: %s", purpose)
 	}
-	if len(group.nodes) == 0 {
+	if len(group.base().nodes) == 0 {
 		return EndpointInfo{}, fmt.Errorf("no valid endpoints available for purpose: %s", purpose)
 	}
-	return group.Next(), nil
+	var node EndpointInfo
+	if keyed, ok := group.(KeyedBalancer); ok && key != "" {
+		node = keyed.NextFor(key)
+	} else {
+		node = group.Next()
+	}
+	if node.Endpoint == "" {
+		return EndpointInfo{}, fmt.Errorf("no healthy endpoints available for purpose: %s", purpose)
+	}
+	node.group = purpose
+	return node, nil
+}
+
+// Stop stops the health checker shared by the groups.
+func (b *definedBalancer) Stop() {
+	for _, group := range b.groups {
+		group.Stop()
+	}
+}
+
+// HasPurpose reports whether a group is configured for purpose.
+func (b *definedBalancer) HasPurpose(purpose string) bool {
+	_, ok := b.groups[purpose]
+	return ok
 }
 
 func (b *definedBalancer) AllEndpoints() []string {
@@ -43,7 +175,7 @@ func (b *definedBalancer) AllEndpoints() []string {
 	var all []string
 
 	for _, group := range b.groups {
-		for _, node := range group.nodes {
+		for _, node := range group.base().nodes {
 			if _, exists := seen[node.Endpoint]; !exists {
 				all = append(all, node.Endpoint)
 				seen[node.Endpoint] = struct{}{}
@@ -52,3 +184,41 @@ func (b *definedBalancer) AllEndpoints() []string {
 	}
 	return all
 }
+
+// addFallbackGroups adds the default and any groups when a fallback chain
+// refers to them and no group of that name is configured.
+func addFallbackGroups(groups map[string][]config.Endpoint, Config config.Config) {
+	referenced := make(map[string]bool)
+	for _, chain := range Config.Fallbacks {
+		for _, name := range chain {
+			referenced[name] = true
+		}
+	}
+
+	if _, exists := groups[DefaultGroup]; referenced[DefaultGroup] && !exists {
+		groups[DefaultGroup] = Config.DgraphEndpoints
+	}
+
+	if _, exists := groups[AnyGroup]; referenced[AnyGroup] && !exists {
+		seen := make(map[string]struct{})
+		var all []config.Endpoint
+		add := func(eps []config.Endpoint) {
+			for _, ep := range eps {
+				if _, dup := seen[ep.Addr]; !dup {
+					all = append(all, ep)
+					seen[ep.Addr] = struct{}{}
+				}
+			}
+		}
+		add(Config.DgraphEndpoints)
+		names := make([]string, 0, len(groups))
+		for name := range groups {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			add(groups[name])
+		}
+		groups[AnyGroup] = all
+	}
+}
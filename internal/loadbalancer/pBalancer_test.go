@@ -0,0 +1,82 @@
+package loadbalancer
+
+import (
+	"testing"
+
+	"github.com/OpenDgraph/Otter/internal/config"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestPurposefulFallbackChain(t *testing.T) {
+	b := NewPurposefulBalancer(config.Config{
+		DgraphEndpoints: config.EndpointsFromAddrs("localhost:9080"),
+		Groups: map[string][]config.Endpoint{
+			"mutation": config.EndpointsFromAddrs("localhost:9081"),
+			"upsert":   config.EndpointsFromAddrs("localhost:9082"),
+		},
+		Fallbacks: map[string][]string{
+			"upsert": {"mutation", DefaultGroup},
+		},
+		Outlier: config.OutlierConfig{Enabled: true, ConsecutiveErrors: 1},
+	}).(*definedBalancer)
+
+	unavailable := Outcome{Err: status.Error(codes.Unavailable, "connection refused")}
+
+	node, err := b.Next("upsert")
+	require.NoError(t, err)
+	require.Equal(t, "localhost:9082", node.Endpoint)
+	b.Observe(node, unavailable)
+
+	node, err = b.Next("upsert")
+	require.NoError(t, err)
+	require.Equal(t, "localhost:9081", node.Endpoint)
+	require.Equal(t, "mutation", node.group, "outcomes must reach the group that served the call")
+	b.Observe(node, unavailable)
+
+	node, err = b.Next("upsert")
+	require.NoError(t, err)
+	require.Equal(t, "localhost:9080", node.Endpoint)
+	b.Observe(node, unavailable)
+
+	_, err = b.Next("upsert")
+	require.EqualError(t, err, "no healthy endpoints available for purpose: upsert; "+
+		"no healthy endpoints available for purpose: mutation; "+
+		"no healthy endpoints available for purpose: default")
+}
+
+func TestPurposefulWildcardFallbackToAny(t *testing.T) {
+	b := NewPurposefulBalancer(config.Config{
+		DgraphEndpoints: config.EndpointsFromAddrs("localhost:9080"),
+		Groups: map[string][]config.Endpoint{
+			"query": config.EndpointsFromAddrs("localhost:9081", "localhost:9080"),
+		},
+		Fallbacks: map[string][]string{
+			WildcardChain: {AnyGroup},
+		},
+	}).(*definedBalancer)
+
+	var any []string
+	for _, node := range b.groups[AnyGroup].base().nodes {
+		any = append(any, node.Endpoint)
+	}
+	require.Equal(t, []string{"localhost:9080", "localhost:9081"}, any)
+
+	node, err := b.Next("schema")
+	require.NoError(t, err)
+	require.Equal(t, AnyGroup, node.group)
+}
+
+func TestPurposefulWithoutFallbacksKeepsError(t *testing.T) {
+	b := NewPurposefulBalancer(config.Config{
+		Groups: map[string][]config.Endpoint{
+			"query": config.EndpointsFromAddrs("localhost:9080"),
+		},
+	})
+
+	_, err := b.Next("mutation")
+	require.EqualError(t, err, "no endpoints defined for purpose: mutation")
+	_, ok := b.(*definedBalancer).groups[DefaultGroup]
+	require.False(t, ok, "synthetic groups are only built when referenced")
+}
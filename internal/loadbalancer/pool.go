@@ -0,0 +1,74 @@
+package loadbalancer
+
+import (
+	"log"
+
+	"github.com/OpenDgraph/Otter/internal/config"
+)
+
+// pool holds the endpoints of a balancer together with the checks that
+// decide whether each of them may currently receive traffic. It is embedded
+// by every strategy so health checks and outlier ejection work the same way
+// regardless of how the next node is picked.
+type pool struct {
+	nodes    []EndpointInfo
+	healthy  func(endpoint string) bool
+	checker  *HealthChecker
+	outliers *OutlierDetector
+}
+
+// poolBalancer is a Balancer built on top of a pool.
+type poolBalancer interface {
+	Balancer
+	Observer
+	Stopper
+	base() *pool
+}
+
+func (p *pool) base() *pool {
+	return p
+}
+
+func (p *pool) available(node EndpointInfo) bool {
+	return p.healthy == nil || p.healthy(node.Endpoint)
+}
+
+func (p *pool) useHealthChecker(checker *HealthChecker) {
+	p.checker = checker
+	p.healthy = allHealthy(p.healthy, checker.Healthy)
+}
+
+// Stop releases the health checker of the pool, if any.
+func (p *pool) Stop() {
+	if p.checker != nil {
+		p.checker.Stop()
+	}
+}
+
+// useOutlierDetector makes the pool skip endpoints ejected by d and forward
+// observed outcomes to it.
+func (p *pool) useOutlierDetector(d *OutlierDetector) {
+	p.outliers = d
+	p.healthy = allHealthy(p.healthy, d.Available)
+}
+
+func (p *pool) report(node EndpointInfo, outcome Outcome) {
+	if p.outliers != nil && !outcome.Refused {
+		p.outliers.Report(node.Endpoint, outcome.Err)
+	}
+}
+
+func parseEndpoints(endpoints []config.Endpoint) []EndpointInfo {
+	nodes := make([]EndpointInfo, 0, len(endpoints))
+
+	for _, ep := range endpoints {
+		httpAddr, err := ep.HTTPAddr()
+		if err != nil {
+			log.Printf("Warning: Ignoring endpoint '%s' in balancer: %v", ep.Addr, err)
+			continue
+		}
+		nodes = append(nodes, EndpointInfo{Endpoint: ep.Addr, HTTP: httpAddr, TLS: ep.TLS, Weight: ep.GetWeight()})
+		log.Printf("Info: Endpoint '%s' added to balancer with http %s and weight %d", ep.Addr, httpAddr, ep.GetWeight())
+	}
+	return nodes
+}
@@ -0,0 +1,118 @@
+package parsing
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/dgraph-io/dgo/v240/protos/api"
+	dqlpkg "github.com/hypermodeinc/dgraph/v24/dql"
+)
+
+// Purposes a request can be classified into. Query and Mutation are the base
+// purposes every request falls back to.
+const (
+	PurposeQuery        = "query"
+	PurposeSchema       = "schema"
+	PurposeTraversal    = "traversal"
+	PurposeAggregation  = "aggregation"
+	PurposeMutation     = "mutation"
+	PurposeBulkMutation = "bulk-mutation"
+	PurposeDelete       = "delete"
+	PurposeUpsert       = "upsert"
+)
+
+// ClassifyQuery returns the purpose of a DQL query: schema for schema
+// introspection, traversal for recurse and shortest-path blocks, aggregation
+// for groupby, min/max/sum/avg and count(uid), query otherwise. Queries that
+// don't parse are left to Dgraph to reject as plain queries.
+func ClassifyQuery(query string) string {
+	AST, err := ParseQuery(query)
+	if err != nil {
+		return PurposeQuery
+	}
+	if AST.Schema != nil {
+		return PurposeSchema
+	}
+
+	purpose := PurposeQuery
+	for _, q := range AST.Query {
+		if isTraversal(q) {
+			// The heaviest kind wins when blocks differ.
+			return PurposeTraversal
+		}
+		if isAggregation(q) {
+			purpose = PurposeAggregation
+		}
+	}
+	return purpose
+}
+
+func isTraversal(q *dqlpkg.GraphQuery) bool {
+	return q.Recurse || q.Alias == "shortest" || q.ShortestPathArgs.From != nil
+}
+
+func isAggregation(q *dqlpkg.GraphQuery) bool {
+	if q.IsGroupby || (q.IsCount && q.Attr == "uid") {
+		return true
+	}
+	if q.IsInternal && q.Func != nil && q.Func.IsAggregator() {
+		return true
+	}
+	for _, child := range q.Children {
+		if isAggregation(child) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClassifyMutation returns the purpose of a mutation: upsert when it carries
+// a query block, delete when it only deletes, bulk-mutation when it holds at
+// least bulkThreshold N-Quads or JSON objects, mutation otherwise.
+// SetNquads may hold a whole DQL mutation block, as sent by HTTP clients.
+func ClassifyMutation(m *api.Mutation, bulkThreshold int) string {
+	mutations := []*api.Mutation{m}
+	if req, err := ParseMutation(string(m.SetNquads)); err == nil {
+		if req.Query != "" {
+			return PurposeUpsert
+		}
+		mutations = req.Mutations
+	}
+
+	var sets, dels int
+	for _, mu := range mutations {
+		sets += countNquads(mu.SetNquads) + countJSON(mu.SetJson)
+		dels += countNquads(mu.DelNquads) + countJSON(mu.DeleteJson)
+	}
+
+	switch {
+	case sets == 0 && dels > 0:
+		return PurposeDelete
+	case bulkThreshold > 0 && sets+dels >= bulkThreshold:
+		return PurposeBulkMutation
+	default:
+		return PurposeMutation
+	}
+}
+
+func countNquads(data []byte) int {
+	n := 0
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) > 0 && line[0] != '#' {
+			n++
+		}
+	}
+	return n
+}
+
+func countJSON(data []byte) int {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return 0
+	}
+	var list []json.RawMessage
+	if err := json.Unmarshal(data, &list); err == nil {
+		return len(list)
+	}
+	return 1
+}
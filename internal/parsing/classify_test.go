@@ -0,0 +1,51 @@
+package parsing
+
+import (
+	"testing"
+
+	"github.com/dgraph-io/dgo/v240/protos/api"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassifyQuery(t *testing.T) {
+	cases := map[string]string{
+		`{ q(func: eq(email, "a@a.com")) { name } }`: PurposeQuery,
+		`schema {}`:                     PurposeSchema,
+		`schema(pred: [name]) { type }`: PurposeSchema,
+		`{ q(func: uid(0x1)) @recurse(depth: 3) { friend } }`:                             PurposeTraversal,
+		`{ path as shortest(from: 0x1, to: 0x2) { friend } p(func: uid(path)) { name } }`: PurposeTraversal,
+		`{ q(func: has(age)) { a as age } s() { min(val(a)) } }`:                          PurposeAggregation,
+		`{ q(func: has(age)) @groupby(age) { count(uid) } }`:                              PurposeAggregation,
+		`{ q(func: has(name)) { count(uid) } }`:                                           PurposeAggregation,
+		`not a query {`:                                                                   PurposeQuery,
+	}
+	for query, want := range cases {
+		require.Equal(t, want, ClassifyQuery(query), query)
+	}
+}
+
+func TestClassifyMutation(t *testing.T) {
+	set := &api.Mutation{SetNquads: []byte(`{ set {
+		_:a <name> "a" .
+		_:b <name> "b" .
+	} }`)}
+	require.Equal(t, PurposeMutation, ClassifyMutation(set, 10))
+	require.Equal(t, PurposeBulkMutation, ClassifyMutation(set, 2))
+
+	raw := &api.Mutation{SetNquads: []byte("_:a <name> \"a\" .\n# comment\n_:b <name> \"b\" .\n")}
+	require.Equal(t, PurposeBulkMutation, ClassifyMutation(raw, 2))
+
+	del := &api.Mutation{SetNquads: []byte(`{ delete { <0x1> * * . } }`)}
+	require.Equal(t, PurposeDelete, ClassifyMutation(del, 10))
+	require.Equal(t, PurposeDelete, ClassifyMutation(&api.Mutation{DeleteJson: []byte(`{"uid":"0x1"}`)}, 10))
+
+	upsert := &api.Mutation{SetNquads: []byte(`upsert {
+		query { q(func: eq(email, "a@a.com")) { v as uid } }
+		mutation { set { uid(v) <name> "a" . } }
+	}`)}
+	require.Equal(t, PurposeUpsert, ClassifyMutation(upsert, 10))
+
+	jsonSet := &api.Mutation{SetJson: []byte(`[{"name":"a"},{"name":"b"},{"name":"c"}]`)}
+	require.Equal(t, PurposeBulkMutation, ClassifyMutation(jsonSet, 3))
+	require.Equal(t, PurposeMutation, ClassifyMutation(jsonSet, 0))
+}
@@ -0,0 +1,32 @@
+package parsing
+
+import (
+	"fmt"
+	"strings"
+)
+
+// QueryShape summarises the root blocks of a DQL query as their root
+// function and predicate, e.g. "eq(email);has(name)". Queries that differ
+// only in their arguments or selected fields share the same shape.
+func QueryShape(query string) (string, error) {
+	AST, err := ParseQuery(query)
+	if err != nil {
+		return "", err
+	}
+
+	var parts []string
+	for _, q := range AST.Query {
+		switch {
+		case q.Func != nil:
+			parts = append(parts, fmt.Sprintf("%s(%s)", q.Func.Name, q.Func.Attr))
+		case len(q.UID) > 0:
+			parts = append(parts, "uid")
+		case q.Attr != "":
+			parts = append(parts, q.Attr)
+		}
+	}
+	if len(parts) == 0 {
+		return "", fmt.Errorf("query has no root function")
+	}
+	return strings.Join(parts, ";"), nil
+}
@@ -0,0 +1,23 @@
+package parsing
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryShape(t *testing.T) {
+	a, err := QueryShape(`{ q(func: eq(email, "a@a.com")) { name } }`)
+	require.NoError(t, err)
+	b, err := QueryShape(`{ other(func: eq(email, "b@b.com")) { uid email friends { name } } }`)
+	require.NoError(t, err)
+	require.Equal(t, "eq(email)", a)
+	require.Equal(t, a, b)
+
+	multi, err := QueryShape(`{ a(func: has(name)) { uid } b(func: uid(0x1)) { uid } }`)
+	require.NoError(t, err)
+	require.Equal(t, "has(name);uid()", multi)
+
+	_, err = QueryShape(`not a query {`)
+	require.Error(t, err)
+}
@@ -0,0 +1,117 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/OpenDgraph/Otter/internal/auth"
+	"github.com/OpenDgraph/Otter/internal/config"
+	"github.com/OpenDgraph/Otter/internal/dgraph"
+	"github.com/OpenDgraph/Otter/internal/loadbalancer"
+)
+
+// ErrNoDgraphUser is returned when dgraph_acl refuses identities without a
+// Dgraph user of their own.
+var ErrNoDgraphUser = errors.New("no Dgraph user is mapped to this identity")
+
+// dgraphTokenHeader carries the access token of calls forwarded to an alpha
+// over HTTP.
+const dgraphTokenHeader = "X-Dgraph-AccessToken"
+
+// acl maps identities to the Dgraph users they act as.
+type acl struct {
+	users     []config.ACLUser // with passwords read from their files
+	unmatched string
+}
+
+func newACL(cfg config.ACLConfig) (*acl, error) {
+	cfg = cfg.WithDefaults()
+	a := &acl{unmatched: cfg.Unmatched}
+	for _, user := range cfg.Users {
+		if user.PasswordFile != "" {
+			password, err := os.ReadFile(user.PasswordFile)
+			if err != nil {
+				return nil, fmt.Errorf("reading password of Dgraph user %s: %w", user.User, err)
+			}
+			user.Password = strings.TrimSpace(string(password))
+		}
+		a.users = append(a.users, user)
+	}
+	return a, nil
+}
+
+// credentials returns the Dgraph user id acts as, or nil when it uses the
+// shared clients.
+func (a *acl) credentials(id *auth.Identity) (*dgraph.Credentials, error) {
+	if a == nil || id == nil {
+		return nil, nil
+	}
+	if id.Dgraph != nil {
+		return &dgraph.Credentials{User: id.Dgraph.User, Password: id.Dgraph.Password, Namespace: id.Namespace}, nil
+	}
+	for _, user := range a.users {
+		if (user.Subject != "" && user.Subject == id.Subject) || (user.Group != "" && id.InGroup(user.Group)) {
+			return &dgraph.Credentials{User: user.User, Password: user.Password, Namespace: user.Namespace}, nil
+		}
+	}
+	if a.unmatched == config.ACLDeny {
+		return nil, ErrNoDgraphUser
+	}
+	return nil, nil
+}
+
+// asUser returns client, or its session acting as the Dgraph user creds.
+func asUser(ctx context.Context, client *dgraph.Client, creds *dgraph.Credentials) (*dgraph.Client, error) {
+	if creds == nil {
+		return client, nil
+	}
+	return client.As(ctx, *creds)
+}
+
+// accessToken returns the access token of the Dgraph user the identity in
+// ctx acts as on node, for calls forwarded over HTTP. It is empty for the
+// shared user, whose calls are forwarded as they came.
+func (p *Proxy) accessToken(ctx context.Context, node loadbalancer.EndpointInfo) (string, error) {
+	b := p.current()
+	creds, err := b.credentials(ctx)
+	if err != nil || creds == nil {
+		return "", err
+	}
+	client, ok := b.clients[node.Endpoint]
+	if !ok {
+		return "", fmt.Errorf("| Dgraph client not found for endpoint %s", node.Endpoint)
+	}
+	session, err := client.As(ctx, *creds)
+	if err != nil {
+		return "", err
+	}
+	return session.AccessJWT(ctx)
+}
+
+// setAccessToken makes h, the headers of a call forwarded to an alpha, carry
+// token. Credentials the client sent are dropped first, so the alpha sees
+// the proxy's token only.
+func setAccessToken(h http.Header, token string) {
+	h.Del("Authorization")
+	h.Del(auth.TokenHeader)
+	h.Set(dgraphTokenHeader, token)
+}
+
+// selectStatus is the HTTP status answered when no backend could be picked
+// for a request.
+func selectStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrNoDgraphUser), errors.Is(err, ErrTenantDenied):
+		return http.StatusForbidden
+	case err.Error() == "no balancer configured":
+		return http.StatusInternalServerError
+	case timedOut(err):
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusServiceUnavailable
+	}
+}
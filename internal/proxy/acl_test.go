@@ -0,0 +1,83 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/OpenDgraph/Otter/internal/auth"
+	"github.com/OpenDgraph/Otter/internal/config"
+	"github.com/OpenDgraph/Otter/internal/dgraph"
+	"github.com/stretchr/testify/require"
+)
+
+func TestACLCredentials(t *testing.T) {
+	passwordFile := filepath.Join(t.TempDir(), "password")
+	require.NoError(t, os.WriteFile(passwordFile, []byte("from-file\n"), 0o600))
+
+	a, err := newACL(config.ACLConfig{Users: []config.ACLUser{
+		{Subject: "alice", User: "alice-dg", Password: "alice-pw", Namespace: 2},
+		{Group: "analysts", User: "reader", PasswordFile: passwordFile},
+	}})
+	require.NoError(t, err)
+
+	creds, err := a.credentials(&auth.Identity{Subject: "alice", Groups: []string{"analysts"}})
+	require.NoError(t, err)
+	require.Equal(t, &dgraph.Credentials{User: "alice-dg", Password: "alice-pw", Namespace: 2}, creds)
+
+	creds, err = a.credentials(&auth.Identity{Subject: "bob", Groups: []string{"analysts"}})
+	require.NoError(t, err)
+	require.Equal(t, &dgraph.Credentials{User: "reader", Password: "from-file"}, creds)
+
+	creds, err = a.credentials(&auth.Identity{
+		Subject: "carol", Namespace: 3, Dgraph: &auth.Credentials{User: "carol", Password: "carol-pw"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, &dgraph.Credentials{User: "carol", Password: "carol-pw", Namespace: 3}, creds)
+
+	creds, err = a.credentials(&auth.Identity{Subject: "dave"})
+	require.NoError(t, err)
+	require.Nil(t, creds, "unmatched identities use the shared user")
+	creds, err = a.credentials(nil)
+	require.NoError(t, err)
+	require.Nil(t, creds)
+
+	a.unmatched = config.ACLDeny
+	_, err = a.credentials(&auth.Identity{Subject: "dave"})
+	require.ErrorIs(t, err, ErrNoDgraphUser)
+}
+
+func TestACLDenyIsForbidden(t *testing.T) {
+	p, err := New(config.Config{
+		BalancerType:    "round-robin",
+		DgraphEndpoints: config.EndpointsFromAddrs("localhost:9080"),
+		DgraphACL:       config.ACLConfig{Unmatched: config.ACLDeny},
+	})
+	require.NoError(t, err)
+
+	ctx := auth.WithIdentity(context.Background(), &auth.Identity{Subject: "dave"})
+	_, _, err = p.SelectClient(ctx)
+	require.ErrorIs(t, err, ErrNoDgraphUser)
+
+	req := httptest.NewRequest(http.MethodGet, "/health", nil).WithContext(ctx)
+	rec := httptest.NewRecorder()
+	p.HandleDirect(rec, req)
+	require.Equal(t, http.StatusForbidden, rec.Code)
+}
+
+func TestSetAccessToken(t *testing.T) {
+	h := http.Header{}
+	h.Set("Authorization", "Basic YWxpY2U6cHc=")
+	h.Set(auth.TokenHeader, "banana")
+	h.Add(dgraphTokenHeader, "client-token")
+	h.Set("Content-Type", "application/json")
+
+	setAccessToken(h, "proxy-token")
+	require.Equal(t, []string{"proxy-token"}, h.Values(dgraphTokenHeader))
+	require.Empty(t, h.Get("Authorization"))
+	require.Empty(t, h.Get(auth.TokenHeader))
+	require.Equal(t, "application/json", h.Get("Content-Type"))
+}
@@ -0,0 +1,105 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/OpenDgraph/Otter/internal/auth"
+	"github.com/OpenDgraph/Otter/internal/config"
+	"github.com/OpenDgraph/Otter/internal/helpers"
+	"github.com/OpenDgraph/Otter/internal/loadbalancer"
+)
+
+// Authenticate checks creds with the authenticators of the current
+// configuration.
+func (p *Proxy) Authenticate(ctx context.Context, creds auth.Credentials) (*auth.Identity, error) {
+	authn := p.current().auth
+	if authn == nil {
+		return nil, auth.ErrUnauthenticated
+	}
+	return authn.Authenticate(ctx, creds)
+}
+
+// RequireAuth authenticates the requests to the routes auth.http marks as
+// required and answers 401 to the callers it rejects. The identity is
+// attached to the request context and the credentials are not forwarded to
+// Dgraph.
+func (p *Proxy) RequireAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodOptions || p.current().configs.Auth.RoutePolicy(r.URL.Path) == config.RoutePublic {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		creds, header, ok := auth.FromRequest(r)
+		if !ok {
+			unauthorized(w, "missing credentials")
+			return
+		}
+		id, err := p.Authenticate(r.Context(), creds)
+		if err != nil {
+			log.Printf("| Authentication failed for %s %s from %s: %v", r.Method, r.URL.Path, r.RemoteAddr, err)
+			unauthorized(w, "invalid credentials")
+			return
+		}
+
+		r = r.WithContext(auth.WithIdentity(r.Context(), id))
+		r.Header.Del(header)
+		next.ServeHTTP(w, r)
+	})
+}
+
+func unauthorized(w http.ResponseWriter, msg string) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="otter"`)
+	helpers.WriteJSONError(w, http.StatusUnauthorized, msg)
+}
+
+// DgraphLogin logs user in on an alpha through its HTTP /login endpoint and
+// returns the access JWT. The Dgraph clients keep their own credentials.
+func (p *Proxy) DgraphLogin(ctx context.Context, user, password string) (string, error) {
+	endpointInfo, target, err := p.selectBackend(ctx, "query")
+	if err != nil {
+		return "", err
+	}
+	target.Path = "/login"
+	body, _ := json.Marshal(map[string]string{"userid": user, "password": password})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target.String(), bytes.NewReader(body))
+	if err != nil {
+		p.ReportOutcome(endpointInfo, loadbalancer.Outcome{Err: err})
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	start := time.Now()
+	resp, err := (&http.Client{Transport: p.transport(endpointInfo)}).Do(req)
+	p.ReportOutcome(endpointInfo, loadbalancer.Outcome{Err: err, Elapsed: time.Since(start)})
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var out struct {
+		Data struct {
+			AccessJWT string `json:"accessJWT"`
+		} `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return "", fmt.Errorf("invalid /login response (status %d): %w", resp.StatusCode, err)
+	}
+	if len(out.Errors) > 0 {
+		return "", errors.New(out.Errors[0].Message)
+	}
+	if out.Data.AccessJWT == "" {
+		return "", fmt.Errorf("no access token in /login response (status %d)", resp.StatusCode)
+	}
+	return out.Data.AccessJWT, nil
+}
@@ -0,0 +1,56 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/OpenDgraph/Otter/internal/auth"
+	"github.com/OpenDgraph/Otter/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequireAuth(t *testing.T) {
+	cfg := config.Config{
+		BalancerType:    "round-robin",
+		DgraphEndpoints: config.EndpointsFromAddrs("localhost:9080"),
+		Auth: config.AuthConfig{
+			APIKeys: []config.APIKey{{Key: "banana", Subject: "demo"}},
+			HTTP:    config.HTTPAuth{Routes: map[string]string{"/health": "public", "/validate/": "public", "/validate/schema": "required"}},
+		},
+	}
+	p, err := New(cfg)
+	require.NoError(t, err)
+
+	var seen *auth.Identity
+	var forwarded http.Header
+	handler := p.RequireAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen, forwarded = auth.IdentityFrom(r.Context()), r.Header
+	}))
+	serve := func(path string, header ...string) int {
+		seen = nil
+		req := httptest.NewRequest(http.MethodPost, path, nil)
+		for i := 0; i < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	require.Equal(t, http.StatusUnauthorized, serve("/alter"))
+	require.Equal(t, http.StatusUnauthorized, serve("/alter", "Authorization", "Bearer apple"))
+	require.Equal(t, http.StatusOK, serve("/alter", "Authorization", "Bearer banana"))
+	require.Equal(t, "demo", seen.Subject)
+	require.Empty(t, forwarded.Get("Authorization"), "credentials are not forwarded to Dgraph")
+	require.Equal(t, http.StatusOK, serve("/query", auth.TokenHeader, "banana"))
+
+	require.Equal(t, http.StatusOK, serve("/health"))
+	require.Nil(t, seen)
+	require.Equal(t, http.StatusOK, serve("/validate/dql"), "prefix route")
+	require.Equal(t, http.StatusUnauthorized, serve("/validate/schema"), "the longest match wins")
+
+	cfg.Auth = config.AuthConfig{}
+	require.NoError(t, p.Reload(cfg))
+	require.Equal(t, http.StatusOK, serve("/alter"), "public without any authentication method")
+}
@@ -0,0 +1,107 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/OpenDgraph/Otter/internal/auth"
+	"github.com/OpenDgraph/Otter/internal/authz"
+	"github.com/OpenDgraph/Otter/internal/helpers"
+	"github.com/OpenDgraph/Otter/internal/ratelimit"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/parser"
+)
+
+// Authorize checks that the caller in ctx may run op under the current
+// policy. A refusal is an *authz.Denied.
+func (p *Proxy) Authorize(ctx context.Context, op authz.Operation) error {
+	return p.current().authz.Authorize(auth.IdentityFrom(ctx), op)
+}
+
+// authorize answers 403 with the denial when the caller of r may not run
+// op, or 429 when it is over its rate limit, and reports whether the
+// request may go on.
+func (p *Proxy) authorize(w http.ResponseWriter, r *http.Request, op authz.Operation) bool {
+	status := http.StatusForbidden
+	err := p.Authorize(r.Context(), op)
+	if err == nil {
+		err = p.AllowRate(r.Context(), op, r.RemoteAddr, "")
+		var exceeded *ratelimit.Exceeded
+		if errors.As(err, &exceeded) {
+			status = http.StatusTooManyRequests
+			w.Header().Set("Retry-After", strconv.Itoa(exceeded.RetryAfterSeconds()))
+		}
+	}
+	if err == nil {
+		return true
+	}
+	log.Printf("| %s %s from %s: %v", r.Method, r.URL.Path, r.RemoteAddr, err)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(err)
+	return false
+}
+
+// graphQLOperation tells documents holding a mutation from reads. A
+// document that does not parse counts as a mutation, Dgraph rejects it
+// anyway.
+func graphQLOperation(query string) authz.Operation {
+	doc, err := parser.ParseQuery(&ast.Source{Input: query})
+	if err != nil {
+		return authz.Mutate
+	}
+	for _, op := range doc.Operations {
+		if op.Operation == ast.Mutation {
+			return authz.Mutate
+		}
+	}
+	return authz.Read
+}
+
+// graphQLRequestOperation is graphQLOperation for the body of a GraphQL
+// HTTP request, which is left for the handler to read again.
+func graphQLRequestOperation(r *http.Request) (authz.Operation, error) {
+	body, err := peekBody(r)
+	if err != nil {
+		return "", err
+	}
+	query := string(body)
+	if strings.HasPrefix(r.Header.Get("Content-Type"), helpers.ContentTypeJSON) {
+		var req struct {
+			Query string `json:"query"`
+		}
+		if json.Unmarshal(body, &req) != nil {
+			return authz.Mutate, nil
+		}
+		query = req.Query
+	}
+	return graphQLOperation(query), nil
+}
+
+// alterOperation returns what an /alter request does: drop all data, or
+// change the schema.
+func alterOperation(body []byte) authz.Operation {
+	var op struct {
+		DropAll bool   `json:"drop_all"`
+		DropOp  string `json:"drop_op"`
+	}
+	if json.Unmarshal(body, &op) == nil && (op.DropAll || op.DropOp == "ALL" || op.DropOp == "DATA") {
+		return authz.DropAll
+	}
+	return authz.Alter
+}
+
+// peekBody reads the body of r and puts it back for the next reader.
+func peekBody(r *http.Request) ([]byte, error) {
+	body, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	return body, err
+}
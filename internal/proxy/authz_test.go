@@ -0,0 +1,89 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/OpenDgraph/Otter/internal/authz"
+	"github.com/OpenDgraph/Otter/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOperations(t *testing.T) {
+	require.Equal(t, authz.Read, graphQLOperation(`{ queryUser { name } }`))
+	require.Equal(t, authz.Read, graphQLOperation(`query Q { a } # mutation`))
+	require.Equal(t, authz.Mutate, graphQLOperation(`query Q { a } mutation M { addUser(input: []) { numUids } }`))
+	require.Equal(t, authz.Mutate, graphQLOperation(`mutation {`))
+
+	require.Equal(t, authz.Alter, alterOperation([]byte(`name: string @index(exact) .`)))
+	require.Equal(t, authz.Alter, alterOperation([]byte(`{"drop_attr": "name"}`)))
+	require.Equal(t, authz.DropAll, alterOperation([]byte(`{"drop_all": true}`)))
+	require.Equal(t, authz.DropAll, alterOperation([]byte(`{"drop_op": "DATA"}`)))
+}
+
+func TestAuthorizeHandlers(t *testing.T) {
+	p, err := New(config.Config{
+		BalancerType:    "round-robin",
+		DgraphEndpoints: config.EndpointsFromAddrs("localhost:9080"),
+		Auth:            config.AuthConfig{APIKeys: []config.APIKey{{Key: "banana", Subject: "demo", Groups: []string{"readers"}}}},
+		Authz:           config.AuthzConfig{Rules: []config.PolicyRule{{Groups: []string{"readers"}, Allow: []string{"read", "alter"}}}},
+	})
+	require.NoError(t, err)
+	handler := p.RequireAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/mutate":
+			p.HandleMutation(w, r)
+		case "/alter":
+			p.HandleDirect(w, r)
+		}
+	}))
+	serve := func(path, contentType, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer banana")
+		req.Header.Set("Content-Type", contentType)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := serve("/mutate", "application/json", `{"set": [{"name": "x"}]}`)
+	require.Equal(t, http.StatusForbidden, rec.Code)
+	require.JSONEq(t, `{"error":"forbidden","operation":"mutate","subject":"demo","reason":"not allowed by any rule"}`, rec.Body.String())
+
+	rec = serve("/alter", "application/json", `{"drop_all": true}`)
+	require.Equal(t, http.StatusForbidden, rec.Code)
+	require.Contains(t, rec.Body.String(), `"operation":"drop_all"`)
+}
+
+func TestRateLimitHandlers(t *testing.T) {
+	p, err := New(config.Config{
+		BalancerType:    "round-robin",
+		DgraphEndpoints: config.EndpointsFromAddrs("localhost:9080"),
+		Auth:            config.AuthConfig{APIKeys: []config.APIKey{{Key: "banana", Subject: "demo"}}},
+		RateLimits:      []config.RateLimit{{By: config.RateBySubject, Mutation: config.Bucket{Rate: 0.5}}},
+	})
+	require.NoError(t, err)
+	handler := p.RequireAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if p.authorize(w, r, authz.Mutate) {
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	serve := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/mutate", nil)
+		req.Header.Set("Authorization", "Bearer banana")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	require.Equal(t, http.StatusNoContent, serve().Code)
+	rec := serve()
+	require.Equal(t, http.StatusTooManyRequests, rec.Code)
+	require.Equal(t, "2", rec.Header().Get("Retry-After"))
+	require.Contains(t, rec.Body.String(), `"class":"mutation"`)
+
+	require.NoError(t, p.AllowRate(context.Background(), authz.Ratel, "", ""), "ratel is not limited")
+}
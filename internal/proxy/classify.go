@@ -0,0 +1,49 @@
+package proxy
+
+import (
+	"github.com/OpenDgraph/Otter/internal/parsing"
+	api "github.com/dgraph-io/dgo/v240/protos/api"
+)
+
+// QueryPurpose returns the purpose a DQL query is balanced under. With
+// classification enabled it is the query's kind when a group is configured
+// for it, and plain "query" otherwise.
+func (p *Proxy) QueryPurpose(query string) string {
+	if !p.classifies() {
+		return parsing.PurposeQuery
+	}
+	return p.purposeOr(parsing.ClassifyQuery(query), parsing.PurposeQuery)
+}
+
+// MutationPurpose is QueryPurpose for mutations, falling back to "mutation".
+func (p *Proxy) MutationPurpose(m *api.Mutation) string {
+	if !p.classifies() {
+		return parsing.PurposeMutation
+	}
+	bulk := p.current().configs.Classify.WithDefaults().BulkThreshold
+	return p.purposeOr(parsing.ClassifyMutation(m, bulk), parsing.PurposeMutation)
+}
+
+// UpsertPurpose returns the purpose of HTTP upsert blocks: "upsert" when
+// classification is enabled and an upsert group is configured, "mutation"
+// otherwise.
+func (p *Proxy) UpsertPurpose() string {
+	if !p.classifies() {
+		return parsing.PurposeMutation
+	}
+	return p.purposeOr(parsing.PurposeUpsert, parsing.PurposeMutation)
+}
+
+// classifies reports whether requests are parsed to pick their purpose; only
+// defined balancing has groups to route them to.
+func (p *Proxy) classifies() bool {
+	b := p.current()
+	return b.configs.Classify.Enabled && b.purposeful != nil
+}
+
+func (p *Proxy) purposeOr(purpose, base string) string {
+	if p.current().purposeful.HasPurpose(purpose) {
+		return purpose
+	}
+	return base
+}
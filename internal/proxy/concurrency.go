@@ -0,0 +1,141 @@
+package proxy
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/OpenDgraph/Otter/internal/admission"
+	"github.com/OpenDgraph/Otter/internal/config"
+	"github.com/OpenDgraph/Otter/internal/loadbalancer"
+)
+
+type priorityCtxKey struct{}
+
+// WithPriority attaches the priority the calls made for ctx wait with.
+func WithPriority(ctx context.Context, priority admission.Priority) context.Context {
+	return context.WithValue(ctx, priorityCtxKey{}, priority)
+}
+
+// PriorityFrom returns the priority attached to ctx, Interactive when none
+// is.
+func PriorityFrom(ctx context.Context) admission.Priority {
+	priority, _ := ctx.Value(priorityCtxKey{}).(admission.Priority)
+	return priority
+}
+
+// PriorityHeader is the request header clients name the priority of their
+// calls in.
+func (p *Proxy) PriorityHeader() string {
+	return p.current().configs.Concurrency.WithDefaults().PriorityHeader
+}
+
+// concurrency holds a limiter for every endpoint and purpose group calls
+// were made to. Limiters outlive reloads, which only change their settings,
+// so a call is always released where it was admitted; the ones without a cap
+// just count. With adaptive limits every endpoint also has an AIMD moving its
+// cap.
+type concurrency struct {
+	mu        sync.Mutex
+	cfg       config.ConcurrencyConfig
+	endpoints map[string]*admission.Limiter
+	groups    map[string]*admission.Limiter
+	adaptive  map[string]*admission.AIMD
+}
+
+// configure applies cfg to the limiters there are and the ones to come.
+func (c *concurrency) configure(cfg config.ConcurrencyConfig) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cfg = cfg.WithDefaults()
+	if !c.cfg.Adaptive.Enabled {
+		c.adaptive = nil
+	}
+	for addr, l := range c.endpoints {
+		l.Configure(c.endpointOptions(addr))
+	}
+	for group, l := range c.groups {
+		l.Configure(c.groupOptions(group))
+	}
+}
+
+// endpointOptions returns the settings of the limiter of addr, creating or
+// updating its AIMD when limits are adaptive. c.mu is held.
+func (c *concurrency) endpointOptions(addr string) admission.Options {
+	opts := admission.Options{Limit: c.cfg.MaxPerEndpoint, QueueSize: c.cfg.QueueSize, QueueTimeout: c.cfg.QueueTimeout}
+	if !c.cfg.Adaptive.Enabled {
+		return opts
+	}
+	ceiling := c.cfg.MaxPerEndpoint
+	if ceiling <= 0 {
+		ceiling = config.DefaultMaxAdaptiveLimit
+	}
+	if c.adaptive == nil {
+		c.adaptive = make(map[string]*admission.AIMD)
+	}
+	if aimd, ok := c.adaptive[addr]; ok {
+		aimd.Configure(c.cfg.Adaptive, ceiling)
+	} else {
+		c.adaptive[addr] = admission.NewAIMD(c.cfg.Adaptive, ceiling)
+	}
+	opts.Limit = c.adaptive[addr].Limit()
+	opts.BatchShare = c.cfg.Adaptive.WithDefaults().BatchShare
+	return opts
+}
+
+func (c *concurrency) groupOptions(group string) admission.Options {
+	return admission.Options{Limit: c.cfg.Groups[group], QueueSize: c.cfg.QueueSize, QueueTimeout: c.cfg.QueueTimeout}
+}
+
+// limiters returns the limiters of a call to node: its purpose group's, if
+// any, then its own, with its AIMD when limits are adaptive.
+func (c *concurrency) limiters(node loadbalancer.EndpointInfo) (group, endpoint *admission.Limiter, aimd *admission.AIMD) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.endpoints == nil {
+		c.cfg = c.cfg.WithDefaults()
+		c.endpoints = make(map[string]*admission.Limiter)
+		c.groups = make(map[string]*admission.Limiter)
+	}
+	if name := node.Group(); name != "" {
+		if group = c.groups[name]; group == nil {
+			group = admission.New(c.groupOptions(name))
+			c.groups[name] = group
+		}
+	}
+	if endpoint = c.endpoints[node.Endpoint]; endpoint == nil {
+		endpoint = admission.New(c.endpointOptions(node.Endpoint))
+		c.endpoints[node.Endpoint] = endpoint
+	}
+	return group, endpoint, c.adaptive[node.Endpoint]
+}
+
+// admit waits until a call to node picked for ctx may start, by the
+// priority in ctx. A refused call only undoes the pick in the balancer: it
+// says nothing about node.
+func (p *Proxy) admit(ctx context.Context, node loadbalancer.EndpointInfo) error {
+	group, endpoint, _ := p.concurrency.limiters(node)
+	priority := PriorityFrom(ctx)
+	err := group.Acquire(ctx, priority)
+	if err == nil {
+		if err = endpoint.Acquire(ctx, priority); err != nil {
+			group.Release()
+		}
+	}
+	if err != nil {
+		p.observe(node, loadbalancer.Outcome{Refused: true})
+	}
+	return err
+}
+
+// release ends a call admitted by admit. Calls that reached the backend
+// move its adaptive cap.
+func (p *Proxy) release(node loadbalancer.EndpointInfo, outcome loadbalancer.Outcome) {
+	group, endpoint, aimd := p.concurrency.limiters(node)
+	endpoint.Release()
+	group.Release()
+	failed := loadbalancer.IsBackendFailure(outcome.Err)
+	if aimd != nil && !outcome.Refused && (outcome.Elapsed > 0 || failed) {
+		endpoint.SetLimit(aimd.Observe(outcome.Elapsed, failed, time.Now()))
+	}
+}
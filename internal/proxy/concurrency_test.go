@@ -0,0 +1,142 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/OpenDgraph/Otter/internal/admission"
+	"github.com/OpenDgraph/Otter/internal/config"
+	"github.com/OpenDgraph/Otter/internal/loadbalancer"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestConcurrencyLimits(t *testing.T) {
+	cfg := config.Config{
+		BalancerType:    "defined",
+		DgraphEndpoints: config.EndpointsFromAddrs("localhost:9080"),
+		Groups: map[string][]config.Endpoint{
+			"query":    config.EndpointsFromAddrs("localhost:9080", "localhost:9081"),
+			"mutation": config.EndpointsFromAddrs("localhost:9082"),
+		},
+		Concurrency: config.ConcurrencyConfig{
+			MaxPerEndpoint: 2,
+			Groups:         map[string]int{"query": 1},
+			QueueTimeout:   20 * time.Millisecond,
+		},
+	}
+	p, err := New(cfg)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	first, _, err := p.SelectClientAuto(ctx, "query")
+	require.NoError(t, err)
+	_, _, err = p.selectBackend(ctx, "query")
+	require.ErrorIs(t, err, admission.ErrQueueTimeout, "the query group is full")
+
+	var mutations []loadbalancer.EndpointInfo
+	for range 2 {
+		node, _, err := p.SelectClientAuto(ctx, "mutation")
+		require.NoError(t, err)
+		mutations = append(mutations, node)
+	}
+	_, _, err = p.SelectClientAuto(ctx, "mutation")
+	require.ErrorIs(t, err, admission.ErrQueueTimeout, "localhost:9082 is full")
+
+	p.ReportOutcome(first, loadbalancer.Outcome{})
+	second, _, err := p.SelectClientAuto(ctx, "query")
+	require.NoError(t, err, "the released slot is free again")
+
+	cfg.Concurrency.MaxPerEndpoint = 3
+	require.NoError(t, p.Reload(cfg))
+	node, _, err := p.SelectClientAuto(ctx, "mutation")
+	require.NoError(t, err, "a reload raises the limit of calls already in flight")
+	mutations = append(mutations, node)
+
+	for _, node := range append(mutations, second) {
+		p.ReportOutcome(node, loadbalancer.Outcome{})
+	}
+	for _, l := range p.concurrency.endpoints {
+		require.Zero(t, l.Inflight())
+	}
+}
+
+func TestAdaptiveShedding(t *testing.T) {
+	p, err := New(config.Config{
+		BalancerType:    "round-robin",
+		DgraphEndpoints: config.EndpointsFromAddrs("localhost:9080"),
+		Concurrency:     config.ConcurrencyConfig{Adaptive: config.AdaptiveConfig{Enabled: true, InitialLimit: 4}},
+	})
+	require.NoError(t, err)
+	req := httptest.NewRequest(http.MethodPost, "/query", nil)
+	req.Header.Set("X-Otter-Priority", "batch")
+	batch := p.routeContext(req, "")
+	require.Equal(t, admission.Batch, PriorityFrom(batch))
+
+	var nodes []loadbalancer.EndpointInfo
+	for range 2 {
+		node, _, err := p.SelectClientAuto(batch, "query")
+		require.NoError(t, err)
+		nodes = append(nodes, node)
+	}
+	_, _, err = p.SelectClientAuto(batch, "query")
+	require.ErrorIs(t, err, admission.ErrShed)
+	require.Equal(t, http.StatusServiceUnavailable, selectStatus(err))
+	node, _, err := p.SelectClientAuto(context.Background(), "query")
+	require.NoError(t, err, "interactive calls use the rest of the cap")
+	nodes = append(nodes, node)
+
+	for _, node := range nodes {
+		p.ReportOutcome(node, loadbalancer.Outcome{Err: status.Error(codes.Unavailable, "overloaded"), Elapsed: time.Second})
+	}
+	_, endpoint, _ := p.concurrency.limiters(node)
+	require.Equal(t, 3, endpoint.Limit(), "failures shrink the cap once")
+}
+
+func TestRefusedAdmissionLeavesBalancerAlone(t *testing.T) {
+	p, err := New(config.Config{
+		BalancerType:    "ewma",
+		DgraphEndpoints: config.EndpointsFromAddrs("localhost:9080"),
+		Outlier:         config.OutlierConfig{Enabled: true, ConsecutiveErrors: 2},
+		Concurrency:     config.ConcurrencyConfig{MaxPerEndpoint: 1, QueueTimeout: 10 * time.Millisecond},
+	})
+	require.NoError(t, err)
+	ctx := context.Background()
+	ewma := p.current().balancer.(*loadbalancer.EWMABalancer)
+	unavailable := status.Error(codes.Unavailable, "down")
+
+	node, _, err := p.SelectClientAuto(ctx, "query")
+	require.NoError(t, err)
+	p.ReportOutcome(node, loadbalancer.Outcome{Err: unavailable, Elapsed: 100 * time.Millisecond})
+	latency := ewma.Latency(node.Endpoint)
+
+	held, _, err := p.SelectClientAuto(ctx, "query")
+	require.NoError(t, err)
+	_, _, err = p.SelectClientAuto(ctx, "query")
+	require.ErrorIs(t, err, admission.ErrQueueTimeout)
+	require.Equal(t, latency, ewma.Latency(node.Endpoint), "a refused call is no latency sample")
+
+	p.ReportOutcome(held, loadbalancer.Outcome{Err: unavailable, Elapsed: 100 * time.Millisecond})
+	_, _, err = p.SelectClientAuto(ctx, "query")
+	require.Error(t, err, "a refused call is no success: two failures in a row eject the alpha")
+}
+
+func TestForbiddenPathTakesNoSlot(t *testing.T) {
+	p, err := New(config.Config{
+		BalancerType:    "round-robin",
+		DgraphEndpoints: config.EndpointsFromAddrs("localhost:9080"),
+		Concurrency:     config.ConcurrencyConfig{MaxPerEndpoint: 1, QueueTimeout: time.Minute},
+	})
+	require.NoError(t, err)
+	held, _, err := p.SelectClientAuto(context.Background(), "query")
+	require.NoError(t, err)
+	defer p.ReportOutcome(held, loadbalancer.Outcome{})
+
+	rec := httptest.NewRecorder()
+	p.HandleDirect(rec, httptest.NewRequest(http.MethodGet, "/admin/shutdown", nil))
+	require.Equal(t, http.StatusForbidden, rec.Code, "refused without waiting for the full endpoint")
+}
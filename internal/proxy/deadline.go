@@ -0,0 +1,74 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// WithTimeout returns ctx ending at the timeout of a call for purpose on
+// route, the HTTP path or "" for WebSocket messages, or at the one the
+// client requested as far as the configuration allows.
+func (p *Proxy) WithTimeout(ctx context.Context, route, purpose string, requested time.Duration) (context.Context, context.CancelFunc) {
+	timeout := p.current().configs.Timeouts.Timeout(route, purpose, requested)
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// TimeoutHeader is the request header clients ask for a timeout in.
+func (p *Proxy) TimeoutHeader() string {
+	return p.current().configs.Timeouts.WithDefaults().Header
+}
+
+// ParseTimeout reads a timeout asked for by a client, a duration such as
+// 1500ms or 2s. An empty one is 0.
+func ParseTimeout(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid timeout %q, expected a duration such as 2s", s)
+	}
+	return d, nil
+}
+
+// callContext returns the context of the backend calls made for r with
+// purpose: routeContext's, ending at the timeout of the call. It fails when
+// the client asked for an invalid timeout.
+func (p *Proxy) callContext(r *http.Request, query, purpose string) (context.Context, context.CancelFunc, error) {
+	requested, err := ParseTimeout(r.Header.Get(p.TimeoutHeader()))
+	if err != nil {
+		return nil, nil, err
+	}
+	ctx, cancel := p.WithTimeout(p.routeContext(r, query), r.URL.Path, purpose, requested)
+	return ctx, cancel, nil
+}
+
+// abandoned reports whether the client went away during a call made with
+// ctx, which then says nothing about the alpha. A call that ran into the
+// deadline Otter set was not abandoned: the alpha was too slow.
+func abandoned(ctx context.Context) bool {
+	return errors.Is(ctx.Err(), context.Canceled)
+}
+
+// failureStatus is the HTTP status of a failed Dgraph call: 504 when it
+// timed out, 500 otherwise.
+func failureStatus(err error) int {
+	if timedOut(err) {
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusInternalServerError
+}
+
+// timedOut reports whether err ended a call at its deadline.
+func timedOut(err error) bool {
+	return errors.Is(err, context.DeadlineExceeded) || status.Code(err) == codes.DeadlineExceeded
+}
@@ -0,0 +1,75 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/OpenDgraph/Otter/internal/config"
+	"github.com/OpenDgraph/Otter/internal/loadbalancer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestTimeouts(t *testing.T) {
+	release := make(chan struct{})
+	alpha := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer alpha.Close()
+	defer close(release)
+
+	p, err := New(config.Config{
+		BalancerType:    "round-robin",
+		DgraphEndpoints: []config.Endpoint{{Addr: "localhost:9080", HTTP: strings.TrimPrefix(alpha.URL, "http://")}},
+		Timeouts:        config.TimeoutConfig{Routes: map[string]time.Duration{"/health": time.Minute}, Max: time.Minute},
+	})
+	require.NoError(t, err)
+	serve := func(timeout string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/health", nil)
+		req.Header.Set("X-Otter-Timeout", timeout)
+		rec := httptest.NewRecorder()
+		p.HandleDirect(rec, req)
+		return rec
+	}
+
+	start := time.Now()
+	require.Equal(t, http.StatusGatewayTimeout, serve("50ms").Code)
+	require.Less(t, time.Since(start), 10*time.Second)
+	require.Equal(t, http.StatusBadRequest, serve("soon").Code)
+
+	_, endpoint, _ := p.concurrency.limiters(loadbalancer.EndpointInfo{Endpoint: "localhost:9080"})
+	require.Zero(t, endpoint.Inflight(), "the timed out call was released")
+}
+
+func TestStalledAlphaIsEjectedUnderTimeouts(t *testing.T) {
+	var calls atomic.Int32
+	alpha := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		<-r.Context().Done()
+	}))
+	defer alpha.Close()
+
+	p, err := New(config.Config{
+		BalancerType:    "round-robin",
+		DgraphEndpoints: []config.Endpoint{{Addr: "localhost:9080", HTTP: strings.TrimPrefix(alpha.URL, "http://")}},
+		Outlier:         config.OutlierConfig{Enabled: true, ConsecutiveErrors: 2},
+		Timeouts:        config.TimeoutConfig{Default: 20 * time.Millisecond},
+	})
+	require.NoError(t, err)
+	serve := func() int {
+		rec := httptest.NewRecorder()
+		p.HandleDirect(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+		return rec.Code
+	}
+
+	require.Equal(t, http.StatusGatewayTimeout, serve())
+	require.Equal(t, http.StatusGatewayTimeout, serve())
+	require.NotEqual(t, http.StatusGatewayTimeout, serve(), "two timeouts in a row eject the alpha")
+	require.Equal(t, int32(2), calls.Load())
+}
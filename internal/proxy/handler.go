@@ -8,7 +8,10 @@ import (
 	"net/http/httputil"
 	"net/url"
 	"sync"
+	"time"
 
+	"github.com/OpenDgraph/Otter/internal/authz"
+	"github.com/OpenDgraph/Otter/internal/dgraph"
 	"github.com/OpenDgraph/Otter/internal/helpers"
 	api "github.com/dgraph-io/dgo/v240/protos/api"
 )
@@ -27,10 +30,30 @@ func (p *Proxy) HandleQuery(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if p.graphQLAllowed() && !isDQL(query) {
-		p.forwardGraphQL(body, w, r)
+	graphQL := p.graphQLAllowed() && !isDQL(query)
+	op := authz.Read
+	if graphQL {
+		op = graphQLOperation(query)
+	}
+	if !p.authorize(w, r, op) {
+		return
+	}
+
+	purpose, routeQuery := "query", ""
+	if !graphQL {
+		purpose, routeQuery = p.QueryPurpose(query), query
+	}
+	ctx, cancel, err := p.callContext(r, routeQuery, purpose)
+	if err != nil {
+		helpers.WriteJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	defer cancel()
+
+	if graphQL {
+		p.forwardGraphQL(ctx, body, w, r)
 	} else {
-		p.runDQLQuery(query, w)
+		p.runDQLQuery(ctx, query, purpose, w)
 	}
 }
 
@@ -48,9 +71,24 @@ func (p *Proxy) HandleMutation(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_, client, err := p.SelectClientAuto("mutation")
+	purpose, op := p.UpsertPurpose(), authz.Upsert
+	if upserts == nil {
+		purpose, op = p.MutationPurpose(mutation), authz.Mutate
+	}
+	if !p.authorize(w, r, op) {
+		return
+	}
+
+	ctx, cancel, err := p.callContext(r, "", purpose)
 	if err != nil {
-		helpers.WriteJSONError(w, http.StatusServiceUnavailable, err.Error())
+		helpers.WriteJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	defer cancel()
+
+	endpointInfo, client, err := p.SelectClientAuto(ctx, purpose)
+	if err != nil {
+		helpers.WriteJSONError(w, selectStatus(err), err.Error())
 		return
 	}
 
@@ -59,7 +97,9 @@ func (p *Proxy) HandleMutation(w http.ResponseWriter, r *http.Request) {
 		var mu sync.Mutex
 		var responses []*api.Response
 		var errs []string
+		var firstErr error
 
+		start := time.Now()
 		for _, up := range upserts {
 			wg.Add(1)
 			go func(up *helpers.UpsertBlock) {
@@ -68,10 +108,15 @@ func (p *Proxy) HandleMutation(w http.ResponseWriter, r *http.Request) {
 					SetNquads: []byte(up.Mutation),
 					Cond:      up.Cond,
 				}
-				resp, err := client.Upsert(context.Background(), up.Query, []*api.Mutation{mut}, true)
+				resp, err := p.whileAborted(ctx, client, func(ctx context.Context, client *dgraph.Client) (*api.Response, error) {
+					return client.Upsert(ctx, up.Query, []*api.Mutation{mut}, true)
+				})
 				mu.Lock()
 				defer mu.Unlock()
 				if err != nil {
+					if firstErr == nil {
+						firstErr = err
+					}
 					errs = append(errs, err.Error())
 				} else {
 					responses = append(responses, resp)
@@ -80,9 +125,11 @@ func (p *Proxy) HandleMutation(w http.ResponseWriter, r *http.Request) {
 		}
 
 		wg.Wait()
+		// All blocks ran on the same endpoint: report them as a single call.
+		p.ReportOutcome(endpointInfo, CallOutcome(ctx, start, nil, firstErr))
 
 		if len(errs) > 0 {
-			helpers.WriteJSONError(w, http.StatusInternalServerError, fmt.Sprintf("Some upserts failed: %v", errs))
+			helpers.WriteJSONError(w, failureStatus(firstErr), fmt.Sprintf("Some upserts failed: %v", errs))
 			return
 		}
 
@@ -90,9 +137,12 @@ func (p *Proxy) HandleMutation(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	resp, err := client.Mutate(context.Background(), mutation)
+	key := r.Header.Get(p.IdempotencyHeader())
+	resp, err := p.RunKeyed(ctx, key, purpose, endpointInfo, client, RetryPolicy{}, func(ctx context.Context, client *dgraph.Client) (*api.Response, error) {
+		return client.Mutate(ctx, mutation)
+	})
 	if err != nil {
-		helpers.WriteJSONError(w, http.StatusInternalServerError, fmt.Sprintf("Error performing mutation: %v", err))
+		helpers.WriteJSONError(w, failureStatus(err), fmt.Sprintf("Error performing mutation: %v", err))
 		return
 	}
 
@@ -107,22 +157,38 @@ func (p *Proxy) HandleDirect(w http.ResponseWriter, r *http.Request) {
 	}
 	const purpose = "query"
 
-	backendHost, err := p.selectBackendHost(purpose, "http")
-	if err != nil {
-		if err.Error() == "no balancer configured" {
-			helpers.WriteJSONError(w, http.StatusInternalServerError, err.Error())
-		} else {
-			helpers.WriteJSONError(w, http.StatusServiceUnavailable, err.Error())
-		}
+	path := r.URL.Path
+	if !allowedPaths[path] {
+		helpers.WriteJSONError(w, http.StatusForbidden, "Path not allowed")
 		return
 	}
 
-	targetURL := &url.URL{Scheme: "http", Host: backendHost}
+	switch path {
+	case "/alter":
+		body, err := peekBody(r)
+		if err != nil {
+			helpers.WriteJSONError(w, http.StatusBadRequest, "Error reading request body")
+			return
+		}
+		if !p.authorize(w, r, alterOperation(body)) {
+			return
+		}
+	case "/admin/schema":
+		if !p.authorize(w, r, authz.Alter) {
+			return
+		}
+	}
 
-	path := r.URL.Path
+	ctx, cancel, err := p.callContext(r, "", purpose)
+	if err != nil {
+		helpers.WriteJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	defer cancel()
 
-	if !allowedPaths[path] {
-		helpers.WriteJSONError(w, http.StatusForbidden, "Path not allowed")
+	endpointInfo, targetURL, err := p.selectBackend(ctx, purpose)
+	if err != nil {
+		helpers.WriteJSONError(w, selectStatus(err), err.Error())
 		return
 	}
 
@@ -133,25 +199,35 @@ func (p *Proxy) HandleDirect(w http.ResponseWriter, r *http.Request) {
 		targetURL.Path = path
 	}
 
-	log.Printf("Proxying health request to %s/health", backendHost)
-	proxy.ServeHTTP(w, r)
+	log.Printf("Proxying health request to %s/health", targetURL.Host)
+	p.serveReverseProxy(proxy, endpointInfo, w, r.WithContext(ctx))
 }
 
 // ! TODO: Add tests
 func (p *Proxy) HandleGraphQL(w http.ResponseWriter, r *http.Request) {
 	const purpose = "query"
 
-	backendHost, err := p.selectBackendHost(purpose, "http")
+	op, err := graphQLRequestOperation(r)
 	if err != nil {
-		if err.Error() == "no balancer configured" {
-			helpers.WriteJSONError(w, http.StatusInternalServerError, err.Error())
-		} else {
-			helpers.WriteJSONError(w, http.StatusServiceUnavailable, err.Error())
-		}
+		helpers.WriteJSONError(w, http.StatusBadRequest, "Error reading request body")
 		return
 	}
+	if !p.authorize(w, r, op) {
+		return
+	}
+
+	ctx, cancel, err := p.callContext(r, "", purpose)
+	if err != nil {
+		helpers.WriteJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	defer cancel()
 
-	targetURL := &url.URL{Scheme: "http", Host: backendHost}
+	endpointInfo, targetURL, err := p.selectBackend(ctx, purpose)
+	if err != nil {
+		helpers.WriteJSONError(w, selectStatus(err), err.Error())
+		return
+	}
 
 	proxy := httputil.NewSingleHostReverseProxy(targetURL)
 	originalDirector := proxy.Director
@@ -160,8 +236,8 @@ func (p *Proxy) HandleGraphQL(w http.ResponseWriter, r *http.Request) {
 		req.URL.Path = "/graphql"
 	}
 
-	log.Printf("Proxying GraphQL request to %s/graphql", backendHost)
-	proxy.ServeHTTP(w, r)
+	log.Printf("Proxying GraphQL request to %s/graphql", targetURL.Host)
+	p.serveReverseProxy(proxy, endpointInfo, w, r.WithContext(ctx))
 }
 
 func (p *Proxy) HandleFrontend(w http.ResponseWriter, r *http.Request) {
@@ -170,10 +246,13 @@ func (p *Proxy) HandleFrontend(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		return
 	}
+	if !p.authorize(w, r, authz.Ratel) {
+		return
+	}
 
 	targetURL := &url.URL{
 		Scheme: "http",
-		Host:   p.configs.Ratel,
+		Host:   p.current().configs.Ratel,
 	}
 
 	proxy := httputil.NewSingleHostReverseProxy(targetURL)
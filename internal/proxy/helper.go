@@ -1,6 +1,7 @@
 package proxy
 
 import (
+	"context"
 	"fmt"
 	"log"
 
@@ -17,21 +18,36 @@ var allowedPaths = map[string]bool{
 }
 
 func (p *Proxy) graphQLAllowed() bool {
-	return p.configs.GraphQL != nil && *p.configs.GraphQL
+	cfg := p.current().configs
+	return cfg.GraphQL != nil && *cfg.GraphQL
 }
 
-func (p *Proxy) SelectClientByPurpose(purpose string) (loadbalancer.EndpointInfo, *dgraph.Client, error) {
-	if p.Purposeful == nil {
+func (p *Proxy) SelectClientByPurpose(ctx context.Context, purpose string) (loadbalancer.EndpointInfo, *dgraph.Client, error) {
+	b := p.current()
+	if b.purposeful == nil {
 		return loadbalancer.EndpointInfo{}, nil, fmt.Errorf("purposeful balancer not initialized")
 	}
+	creds, err := b.credentials(ctx)
+	if err != nil {
+		return loadbalancer.EndpointInfo{}, nil, err
+	}
 
-	endpointInfo, err := p.Purposeful.Next(purpose)
+	endpointInfo, err := b.nextEndpointByPurpose(ctx, purpose)
 	if err != nil {
 		return loadbalancer.EndpointInfo{}, nil, err
 	}
-	client, ok := p.clients[endpointInfo.Endpoint]
+	if err := p.admit(ctx, endpointInfo); err != nil {
+		return loadbalancer.EndpointInfo{}, nil, err
+	}
+	client, ok := b.clients[endpointInfo.Endpoint]
 	if !ok {
-		return loadbalancer.EndpointInfo{}, nil, fmt.Errorf("| Dgraph client not found for endpoint %s", endpointInfo.Endpoint)
+		err := fmt.Errorf("| Dgraph client not found for endpoint %s", endpointInfo.Endpoint)
+		p.ReportOutcome(endpointInfo, loadbalancer.Outcome{Err: err})
+		return loadbalancer.EndpointInfo{}, nil, err
+	}
+	if client, err = asUser(ctx, client, creds); err != nil {
+		p.ReportOutcome(endpointInfo, loadbalancer.Outcome{Err: err})
+		return loadbalancer.EndpointInfo{}, nil, err
 	}
 	log.Printf("| ByPurpose | Selected Dgraph endpoint: %s", endpointInfo.Endpoint)
 	return endpointInfo, client, nil
@@ -1,106 +1,249 @@
 package proxy
 
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
-	"net"
-	"strconv"
-
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+
+	"github.com/OpenDgraph/Otter/internal/auth"
+	"github.com/OpenDgraph/Otter/internal/authz"
 	"github.com/OpenDgraph/Otter/internal/config"
 	"github.com/OpenDgraph/Otter/internal/dgraph"
+	"github.com/OpenDgraph/Otter/internal/discovery"
 	"github.com/OpenDgraph/Otter/internal/loadbalancer"
+	"github.com/OpenDgraph/Otter/internal/ratelimit"
+	"github.com/OpenDgraph/Otter/internal/retry"
+	"github.com/OpenDgraph/Otter/internal/tlsconfig"
+	api "github.com/dgraph-io/dgo/v240/protos/api"
 )
 
 type Proxy struct {
+	backends    atomic.Pointer[backends]
+	concurrency concurrency
+	retries     retry.Budget
+	idempotency retry.Dedup[*api.Response] // results of keyed writes
+
+	mu        sync.Mutex // serialises rebuilds
+	discovery *discovery.Watcher
+	topology  discovery.Topology
+}
+
+// backends is the part of the proxy rebuilt on reload or when the cluster
+// changes. It is swapped as a whole, so a request sees one configuration and
+// picks its endpoint and its client from the same set.
+type backends struct {
 	balancer   loadbalancer.Balancer
-	Purposeful loadbalancer.PurposefulBalancer
+	purposeful loadbalancer.PurposefulBalancer
 	clients    map[string]*dgraph.Client
-	configs    config.Config
+	endpoints  map[string]config.Endpoint // what each client connects to
+	transports map[config.ClientTLS]*http.Transport
+	auth       auth.Authenticator
+	authz      *authz.Policy
+	acl        *acl
+	tenants    *tenants
+	limiter    *ratelimit.Limiter
+	configs    config.Config // as configured, before discovery
 }
 
-func NewPurposefulProxy(balancer loadbalancer.PurposefulBalancer, Config config.Config) (*Proxy, error) {
-	clients := map[string]*dgraph.Client{}
+// New builds the balancer selected by Config.BalancerType and the proxy on
+// top of it. With discovery enabled the alphas are first read from Zero and
+// the proxy keeps following the cluster afterwards.
+func New(Config config.Config) (*Proxy, error) {
+	p := &Proxy{}
+	if err := p.Reload(Config); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// newBackends builds the balancer and clients for effective, the
+// configuration with discovered alphas applied. Clients of old are reused
+// when their connection settings did not change.
+func newBackends(effective, configured config.Config, old *backends) (*backends, error) {
+	effective = effective.WithEndpointTLS()
+	acl, err := newACL(configured.DgraphACL)
+	if err != nil {
+		return nil, err
+	}
+	var oldTenants *tenants
+	if old != nil {
+		oldTenants = old.tenants
+	}
+	tenants, err := newTenants(configured, oldTenants)
+	if err != nil {
+		return nil, err
+	}
+	b := &backends{acl: acl, tenants: tenants, configs: configured}
+	var endpoints []config.Endpoint
+	switch effective.BalancerType {
+	case "defined", "purposeful":
+		b.purposeful = loadbalancer.NewPurposefulBalancer(effective)
+		endpoints = groupEndpoints(b.purposeful, effective)
+	default:
+		balancer, err := loadbalancer.NewBalancer(effective)
+		if err != nil {
+			return nil, fmt.Errorf("error creating balancer: %w", err)
+		}
+		b.balancer = balancer
+		endpoints = effective.DgraphEndpoints
+	}
 
-	for _, ep := range balancer.AllEndpoints() {
-		user := Config.DgraphUser
-		password := Config.DgraphPassword
+	if err := b.connect(endpoints, effective, old); err != nil {
+		b.stop()
+		return nil, err
+	}
+	idle := configured.DgraphACL.WithDefaults().IdleTimeout
+	for _, client := range b.clients {
+		client.SetSessionIdle(idle)
+	}
+	return b, nil
+}
 
-		if _, ok := clients[ep]; ok {
+// connect creates a client for every endpoint, taking it from old when old
+// connects to the same address with the same settings and credentials.
+func (b *backends) connect(endpoints []config.Endpoint, Config config.Config, old *backends) error {
+	b.clients = make(map[string]*dgraph.Client, len(endpoints))
+	b.endpoints = make(map[string]config.Endpoint, len(endpoints))
+	b.transports = make(map[config.ClientTLS]*http.Transport)
+	for _, ep := range endpoints {
+		if _, ok := b.clients[ep.Addr]; ok {
 			continue
 		}
-		client, err := dgraph.NewClient(ep, user, password)
+		ep.Weight = 0 // not a connection setting
+		tlsConfig, err := tlsconfig.Client(ep.TLS)
 		if err != nil {
-			return nil, fmt.Errorf("error creating Dgraph client for %s: %w", ep, err)
+			b.closeNew(old)
+			return fmt.Errorf("error configuring TLS for %s: %w", ep.Addr, err)
+		}
+		if tlsConfig != nil && b.transports[ep.TLS] == nil {
+			b.transports[ep.TLS] = newTransport(tlsConfig)
 		}
-		clients[ep] = client
+		if client, ok := old.reusable(ep, Config); ok {
+			b.clients[ep.Addr], b.endpoints[ep.Addr] = client, ep
+			continue
+		}
+		client, err := dgraph.NewClient(ep.Addr, Config.DgraphUser, Config.DgraphPassword, tlsConfig)
+		if err != nil {
+			b.closeNew(old)
+			return fmt.Errorf("error creating Dgraph client for %s: %w", ep.Addr, err)
+		}
+		b.clients[ep.Addr], b.endpoints[ep.Addr] = client, ep
 	}
+	return nil
+}
 
-	return &Proxy{
-		Purposeful: balancer,
-		clients:    clients,
-		configs:    Config,
-	}, nil
+func (b *backends) reusable(ep config.Endpoint, Config config.Config) (*dgraph.Client, bool) {
+	if b == nil || b.configs.DgraphUser != Config.DgraphUser || b.configs.DgraphPassword != Config.DgraphPassword {
+		return nil, false
+	}
+	client, ok := b.clients[ep.Addr]
+	if !ok || b.endpoints[ep.Addr] != ep {
+		return nil, false
+	}
+	return client, true
 }
 
-func NewProxy(balancer loadbalancer.Balancer, Config config.Config) (*Proxy, error) {
-	user := Config.DgraphUser
-	password := Config.DgraphPassword
-	endpoints := Config.DgraphEndpoints
+// closeNew closes the clients b created itself after a failed build.
+func (b *backends) closeNew(old *backends) {
+	for ep, client := range b.clients {
+		if old == nil || old.clients[ep] != client {
+			client.Close()
+		}
+	}
+}
 
-	clients := make(map[string]*dgraph.Client)
-	for _, endpoint := range endpoints {
-		client, err := dgraph.NewClient(endpoint, user, password)
-		if err != nil {
-			return nil, fmt.Errorf("error creating Dgraph client for %s: %w", endpoint, err)
+func newTransport(tlsConfig *tls.Config) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return transport
+}
+
+// transport returns the round tripper for HTTP calls to node: the default
+// one in plaintext, one carrying the node's CA and client certificate with
+// TLS.
+func (p *Proxy) transport(node loadbalancer.EndpointInfo) http.RoundTripper {
+	if !node.TLS.Enabled {
+		return http.DefaultTransport
+	}
+	if transport, ok := p.current().transports[node.TLS]; ok {
+		return transport
+	}
+	// node was picked from backends replaced since; build a transport for
+	// this call alone.
+	tlsConfig, err := tlsconfig.Client(node.TLS)
+	if err != nil {
+		log.Printf("| Error configuring TLS for %s: %v", node.Endpoint, err)
+	}
+	transport := newTransport(tlsConfig)
+	transport.DisableKeepAlives = true
+	return transport
+}
+
+// groupEndpoints returns the configured endpoints the purposeful balancer
+// can pick from.
+func groupEndpoints(balancer loadbalancer.PurposefulBalancer, Config config.Config) []config.Endpoint {
+	configured := make(map[string]config.Endpoint)
+	for _, ep := range Config.DgraphEndpoints {
+		configured[ep.Addr] = ep
+	}
+	for _, eps := range Config.Groups {
+		for _, ep := range eps {
+			configured[ep.Addr] = ep
 		}
-		clients[endpoint] = client
 	}
 
-	return &Proxy{
-		balancer: balancer,
-		clients:  clients,
-		configs:  Config,
-	}, nil
+	var endpoints []config.Endpoint
+	for _, addr := range balancer.AllEndpoints() {
+		ep, ok := configured[addr]
+		if !ok {
+			ep = config.Endpoint{Addr: addr}
+		}
+		endpoints = append(endpoints, ep)
+	}
+	return endpoints
+}
+
+// current returns the backends requests are served from right now.
+func (p *Proxy) current() *backends {
+	return p.backends.Load()
 }
 
-func (p *Proxy) selectBackendHost(purpose, protocol string) (string, error) {
+// selectBackend picks an alpha for purpose and returns the base URL of its
+// HTTP listener.
+func (p *Proxy) selectBackend(ctx context.Context, purpose string) (loadbalancer.EndpointInfo, *url.URL, error) {
 	var endpointInfo loadbalancer.EndpointInfo
 	var err error
 
-	if p.Purposeful != nil {
-		endpointInfo, err = p.Purposeful.Next(purpose)
-	} else if p.balancer != nil {
-		endpointInfo = p.balancer.Next()
+	b := p.current()
+	if err := b.checkPurpose(ctx, purpose); err != nil {
+		return loadbalancer.EndpointInfo{}, nil, err
+	}
+	if _, err := b.credentials(ctx); err != nil {
+		return loadbalancer.EndpointInfo{}, nil, err
+	}
+	if b.purposeful != nil {
+		endpointInfo, err = b.nextEndpointByPurpose(ctx, purpose)
+	} else if b.balancer != nil {
+		endpointInfo = b.nextEndpoint(ctx)
 	} else {
-		return "", fmt.Errorf("no balancer configured")
+		return loadbalancer.EndpointInfo{}, nil, fmt.Errorf("no balancer configured")
 	}
 
 	if err != nil {
-		return "", fmt.Errorf("error selecting backend for purpose '%s': %w", purpose, err)
+		return loadbalancer.EndpointInfo{}, nil, fmt.Errorf("error selecting backend for purpose '%s': %w", purpose, err)
 	}
 
 	if endpointInfo.Endpoint == "" {
-		return "", fmt.Errorf("no available backend for purpose '%s'", purpose)
+		return loadbalancer.EndpointInfo{}, nil, fmt.Errorf("no available backend for purpose '%s'", purpose)
 	}
-
-	host, portStr, splitErr := net.SplitHostPort(endpointInfo.Endpoint)
-	if splitErr != nil {
-		return "", fmt.Errorf("invalid endpoint format '%s': %w", endpointInfo.Endpoint, splitErr)
-	}
-
-	port, parseErr := strconv.Atoi(portStr)
-	if parseErr != nil {
-		return "", fmt.Errorf("invalid port in endpoint '%s': %w", endpointInfo.Endpoint, parseErr)
-	}
-
-	switch protocol {
-	case "http":
-		// Se gRPC estiver usando 90XX, http é 80XX
-		port -= 1000
-	case "grpc":
-		// nada a fazer, usa porta original
-	default:
-		return "", fmt.Errorf("unsupported protocol: %s", protocol)
+	if err := p.admit(ctx, endpointInfo); err != nil {
+		return loadbalancer.EndpointInfo{}, nil, err
 	}
 
-	return fmt.Sprintf("%s:%d", host, port), nil
+	return endpointInfo, endpointInfo.HTTPURL(), nil
 }
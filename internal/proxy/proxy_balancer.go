@@ -1,29 +1,139 @@
 package proxy
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"time"
 
 	"github.com/OpenDgraph/Otter/internal/dgraph"
 	"github.com/OpenDgraph/Otter/internal/loadbalancer"
+	api "github.com/dgraph-io/dgo/v240/protos/api"
 )
 
-func (p *Proxy) SelectClientAuto(purpose string) (loadbalancer.EndpointInfo, *dgraph.Client, error) {
-	if p.Purposeful != nil {
-		return p.SelectClientByPurpose(purpose)
+// SelectClientAuto picks a client for a call made for purpose, once the
+// tenant in ctx is allowed to make it.
+func (p *Proxy) SelectClientAuto(ctx context.Context, purpose string) (loadbalancer.EndpointInfo, *dgraph.Client, error) {
+	if err := p.current().checkPurpose(ctx, purpose); err != nil {
+		return loadbalancer.EndpointInfo{}, nil, err
 	}
-	return p.SelectClient()
+	if p.current().purposeful != nil {
+		return p.SelectClientByPurpose(ctx, purpose)
+	}
+	return p.SelectClient(ctx)
 }
 
-func (p *Proxy) SelectClient() (loadbalancer.EndpointInfo, *dgraph.Client, error) {
-	endpointInfo := p.balancer.Next()
+// ReportOutcome ends a backend call: it frees its concurrency slot and
+// feeds the result back to the adaptive cap of the endpoint and to the
+// balancer that picked it, when that balancer learns from outcomes.
+func (p *Proxy) ReportOutcome(endpointInfo loadbalancer.EndpointInfo, outcome loadbalancer.Outcome) {
+	if endpointInfo.Endpoint == "" {
+		return
+	}
+	p.release(endpointInfo, outcome)
+	p.observe(endpointInfo, outcome)
+}
+
+// observe hands outcome to the balancer that picked endpointInfo, which a
+// reload may have replaced since, or to the current one for endpoints that
+// do not name theirs.
+func (p *Proxy) observe(endpointInfo loadbalancer.EndpointInfo, outcome loadbalancer.Outcome) {
+	observer := endpointInfo.Observer()
+	if observer == nil {
+		observer = p.current().observer()
+	}
+	if observer != nil {
+		observer.Observe(endpointInfo, outcome)
+	}
+}
+
+// observer returns the balancer of b when it learns from outcomes.
+func (b *backends) observer() loadbalancer.Observer {
+	var observer loadbalancer.Observer
+	if b.purposeful != nil {
+		observer, _ = b.purposeful.(loadbalancer.Observer)
+	} else if b.balancer != nil {
+		observer, _ = b.balancer.(loadbalancer.Observer)
+	}
+	return observer
+}
+
+// picked marks node as handed out by the balancer of b, so the outcome of
+// the call goes back to it.
+func (b *backends) picked(node loadbalancer.EndpointInfo) loadbalancer.EndpointInfo {
+	if node.Endpoint == "" {
+		return node
+	}
+	return node.WithObserver(b.observer())
+}
+
+// CallOutcome describes a Dgraph call made with ctx that started at start,
+// including the server-side latency reported in resp when the call
+// succeeded. A call the client abandoned only undoes the pick.
+func CallOutcome(ctx context.Context, start time.Time, resp *api.Response, err error) loadbalancer.Outcome {
+	return loadbalancer.Outcome{
+		Err:           err,
+		Elapsed:       time.Since(start),
+		ServerLatency: time.Duration(resp.GetLatency().GetTotalNs()),
+		Refused:       err != nil && abandoned(ctx),
+	}
+}
+
+func (p *Proxy) SelectClient(ctx context.Context) (loadbalancer.EndpointInfo, *dgraph.Client, error) {
+	b := p.current()
+	creds, err := b.credentials(ctx)
+	if err != nil {
+		return loadbalancer.EndpointInfo{}, nil, err
+	}
+	endpointInfo := b.nextEndpoint(ctx)
 	if endpointInfo.Endpoint == "" {
 		return loadbalancer.EndpointInfo{}, nil, fmt.Errorf("| No Dgraph endpoints available")
 	}
-	client, ok := p.clients[endpointInfo.Endpoint]
+	if err := p.admit(ctx, endpointInfo); err != nil {
+		return loadbalancer.EndpointInfo{}, nil, err
+	}
+	client, ok := b.clients[endpointInfo.Endpoint]
 	if !ok {
-		return loadbalancer.EndpointInfo{}, nil, fmt.Errorf("| Dgraph client not found for endpoint %s", endpointInfo.Endpoint)
+		err := fmt.Errorf("| Dgraph client not found for endpoint %s", endpointInfo.Endpoint)
+		p.ReportOutcome(endpointInfo, loadbalancer.Outcome{Err: err})
+		return loadbalancer.EndpointInfo{}, nil, err
+	}
+	if client, err = asUser(ctx, client, creds); err != nil {
+		p.ReportOutcome(endpointInfo, loadbalancer.Outcome{Err: err})
+		return loadbalancer.EndpointInfo{}, nil, err
 	}
 	log.Printf("| Selected Dgraph endpoint: %s", endpointInfo.Endpoint)
 	return endpointInfo, client, nil
 }
+
+// nextEndpoint asks the simple balancer for an endpoint, by routing key when
+// the balancer supports it and ctx carries one.
+func (b *backends) nextEndpoint(ctx context.Context) loadbalancer.EndpointInfo {
+	if keyed, ok := b.balancer.(loadbalancer.KeyedBalancer); ok {
+		if key := RouteKeyFrom(ctx); key != "" {
+			return b.picked(keyed.NextFor(key))
+		}
+	}
+	return b.picked(b.balancer.Next())
+}
+
+// nextEndpointByPurpose is nextEndpoint for the purposeful balancer. Calls
+// of a tenant with groups of its own are served from those.
+func (b *backends) nextEndpointByPurpose(ctx context.Context, purpose string) (loadbalancer.EndpointInfo, error) {
+	node, ok, err := b.tenantEndpoint(ctx)
+	if !ok {
+		node, err = b.nextFromPurposeful(ctx, purpose)
+	}
+	return b.picked(node), err
+}
+
+// nextFromPurposeful asks the purposeful balancer for an endpoint of the
+// purpose group, by routing key when ctx carries one.
+func (b *backends) nextFromPurposeful(ctx context.Context, purpose string) (loadbalancer.EndpointInfo, error) {
+	if keyed, ok := b.purposeful.(loadbalancer.KeyedPurposefulBalancer); ok {
+		if key := RouteKeyFrom(ctx); key != "" {
+			return keyed.NextFor(purpose, key)
+		}
+	}
+	return b.purposeful.Next(purpose)
+}
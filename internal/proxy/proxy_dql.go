@@ -7,17 +7,25 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/OpenDgraph/Otter/internal/dgraph"
 	"github.com/OpenDgraph/Otter/internal/helpers"
+	api "github.com/dgraph-io/dgo/v240/protos/api"
 )
 
-func (p *Proxy) runDQLQuery(query string, w http.ResponseWriter) {
-	_, client, err := p.SelectClientAuto("query")
+func (p *Proxy) runDQLQuery(ctx context.Context, query, purpose string, w http.ResponseWriter) {
+	endpointInfo, client, err := p.SelectClientAuto(ctx, purpose)
 	if err != nil {
-		helpers.WriteJSONError(w, http.StatusServiceUnavailable, err.Error())
+		helpers.WriteJSONError(w, selectStatus(err), err.Error())
 		return
 	}
 
-	resp, err := client.Query(context.Background(), query)
+	resp, err := p.Run(ctx, purpose, endpointInfo, client, RetryPolicy{Idempotent: true}, func(ctx context.Context, client *dgraph.Client) (*api.Response, error) {
+		return client.Query(ctx, query)
+	})
+	if timedOut(err) {
+		helpers.WriteJSONError(w, http.StatusGatewayTimeout, fmt.Sprintf("Error querying Dgraph: %v", err))
+		return
+	}
 	if err != nil {
 		helpers.WriteJSONQueryError(w, fmt.Sprintf("Error querying Dgraph: %v", err))
 		return
@@ -3,35 +3,50 @@ package proxy
 import (
 	"bytes"
 	"compress/gzip"
+	"context"
 	"io"
 	"net/http"
-	"net/url"
+	"time"
 
 	"github.com/OpenDgraph/Otter/internal/helpers"
+	"github.com/OpenDgraph/Otter/internal/loadbalancer"
 )
 
-func (p *Proxy) forwardGraphQL(body []byte, w http.ResponseWriter, r *http.Request) {
+func (p *Proxy) forwardGraphQL(ctx context.Context, body []byte, w http.ResponseWriter, r *http.Request) {
 	const purpose = "query"
 
-	backendHost, err := p.selectBackendHost(purpose, "http")
+	endpointInfo, reqURL, err := p.selectBackend(ctx, purpose)
 	if err != nil {
-		status := http.StatusServiceUnavailable
-		if err.Error() == "no balancer configured" {
-			status = http.StatusInternalServerError
-		}
-		helpers.WriteJSONError(w, status, err.Error())
+		helpers.WriteJSONError(w, selectStatus(err), err.Error())
 		return
 	}
 
-	reqURL := &url.URL{Scheme: "http", Host: backendHost, Path: "/graphql"}
-	req2, err := http.NewRequest("POST", reqURL.String(), bytes.NewReader(body))
+	reqURL.Path = "/graphql"
+	req2, err := http.NewRequestWithContext(ctx, "POST", reqURL.String(), bytes.NewReader(body))
 	if err != nil {
+		p.ReportOutcome(endpointInfo, loadbalancer.Outcome{Err: err})
 		helpers.WriteJSONError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 	req2.Header = r.Header.Clone()
+	token, err := p.accessToken(ctx, endpointInfo)
+	if err != nil {
+		p.ReportOutcome(endpointInfo, loadbalancer.Outcome{Err: err})
+		helpers.WriteJSONError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	if token != "" {
+		setAccessToken(req2.Header, token)
+	}
 
-	resp2, err := http.DefaultClient.Do(req2)
+	start := time.Now()
+	client := &http.Client{Transport: p.transport(endpointInfo)}
+	resp2, err := client.Do(req2)
+	p.ReportOutcome(endpointInfo, CallOutcome(ctx, start, nil, err))
+	if timedOut(err) {
+		helpers.WriteJSONError(w, http.StatusGatewayTimeout, err.Error())
+		return
+	}
 	if err != nil {
 		helpers.WriteJSONError(w, http.StatusServiceUnavailable, err.Error())
 		return
@@ -0,0 +1,44 @@
+package proxy
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/OpenDgraph/Otter/internal/auth"
+	"github.com/OpenDgraph/Otter/internal/authz"
+	"github.com/OpenDgraph/Otter/internal/ratelimit"
+)
+
+// AllowRate takes a token for op from the rate limits of the caller in ctx,
+// coming from remoteAddr over the WebSocket session, if any. Over a limit
+// it returns a *ratelimit.Exceeded.
+func (p *Proxy) AllowRate(ctx context.Context, op authz.Operation, remoteAddr, session string) error {
+	class, ok := rateClass(op)
+	if !ok {
+		return nil
+	}
+	keys := ratelimit.Keys{Tenant: TenantFrom(ctx), Session: session}
+	if id := auth.IdentityFrom(ctx); id != nil {
+		keys.Subject = id.Method + ":" + id.Subject
+	}
+	keys.IP = remoteAddr
+	if host, _, err := net.SplitHostPort(remoteAddr); err == nil {
+		keys.IP = host
+	}
+	return p.current().limiter.Allow(keys, class, time.Now())
+}
+
+// rateClass returns the budget op is counted against. Schema changes count
+// as mutations; loading Ratel is not limited.
+func rateClass(op authz.Operation) (ratelimit.Class, bool) {
+	switch op {
+	case authz.Read:
+		return ratelimit.Query, true
+	case authz.Mutate, authz.Alter, authz.DropAll:
+		return ratelimit.Mutation, true
+	case authz.Upsert:
+		return ratelimit.Upsert, true
+	}
+	return "", false
+}
@@ -0,0 +1,217 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"reflect"
+	"time"
+
+	"github.com/OpenDgraph/Otter/internal/auth"
+	"github.com/OpenDgraph/Otter/internal/authz"
+	"github.com/OpenDgraph/Otter/internal/config"
+	"github.com/OpenDgraph/Otter/internal/discovery"
+	"github.com/OpenDgraph/Otter/internal/loadbalancer"
+	"github.com/OpenDgraph/Otter/internal/ratelimit"
+)
+
+// clientRetireGrace is how long a replaced client stays open for requests
+// that picked it just before the swap; it is closed once idle afterwards.
+const clientRetireGrace = 5 * time.Second
+
+// Reload rebuilds the balancers, purpose groups, Dgraph clients,
+// authenticators, authorization policy, rate limits, concurrency limits and
+// retry budget from Config and swaps them in. Requests already running
+// finish on the backends they started with; WebSocket sessions pick from the
+// new ones on their next message. On error the current backends are kept.
+func (p *Proxy) Reload(Config config.Config) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	old := p.current()
+	if old != nil {
+		warnRestartOnly(old.configs, Config)
+	}
+
+	authn, err := auth.New(Config.Auth, p.DgraphLogin)
+	if err != nil {
+		return fmt.Errorf("error configuring authentication: %w", err)
+	}
+	policy, err := authz.New(Config.Authz)
+	if err != nil {
+		return fmt.Errorf("error loading authorization policy: %w", err)
+	}
+
+	// Buckets survive a reload that leaves the limits alone, or every
+	// reload would hand out a fresh burst.
+	limiter := ratelimit.New(Config.RateLimits)
+	if old != nil && reflect.DeepEqual(old.configs.RateLimits, Config.RateLimits) {
+		limiter = old.limiter
+	}
+
+	watcher, topology := p.discovery, p.topology
+	restartDiscovery := old == nil || !reflect.DeepEqual(old.configs.Discovery, Config.Discovery)
+	if restartDiscovery {
+		if watcher, topology, err = p.newDiscovery(Config); err != nil {
+			return err
+		}
+	}
+
+	effective := Config
+	if len(topology.Alphas) > 0 {
+		effective = discovery.Apply(Config, topology)
+	}
+	next, err := newBackends(effective, Config, old)
+	if err != nil {
+		return err
+	}
+	next.auth, next.authz, next.limiter = authn, policy, limiter
+	p.backends.Store(next)
+	p.concurrency.configure(Config.Concurrency)
+	p.configureRetries(Config.Retries)
+	p.topology = topology
+
+	if restartDiscovery {
+		if p.discovery != nil {
+			p.discovery.Stop()
+		}
+		p.discovery = watcher
+		if watcher != nil {
+			watcher.Start(topology)
+		}
+	}
+	if old != nil {
+		old.retire(next)
+		log.Printf("| Configuration reloaded")
+	}
+	return nil
+}
+
+// newDiscovery creates the Zero watcher for Config, not started yet, and
+// reads the first topology. Without discovery both are zero.
+func (p *Proxy) newDiscovery(Config config.Config) (*discovery.Watcher, discovery.Topology, error) {
+	if !Config.Discovery.Enabled() {
+		return nil, discovery.Topology{}, nil
+	}
+
+	var watcher *discovery.Watcher
+	watcher, err := discovery.NewWatcher(Config.Discovery, func(topology discovery.Topology) {
+		p.applyTopology(watcher, topology)
+	})
+	if err != nil {
+		return nil, discovery.Topology{}, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), Config.Discovery.WithDefaults().Timeout)
+	defer cancel()
+	topology, err := watcher.Fetch(ctx)
+	switch {
+	case err == nil && len(topology.Alphas) > 0:
+		log.Printf("| Discovered %d alphas from Zero", len(topology.Alphas))
+		return watcher, topology, nil
+	case len(Config.DgraphEndpoints) > 0:
+		log.Printf("Warning: Discovery failed, starting with dgraph_endpoints: %v", err)
+		return watcher, discovery.Topology{}, nil
+	default:
+		return nil, discovery.Topology{}, fmt.Errorf("no alphas discovered and no dgraph_endpoints configured: %w", err)
+	}
+}
+
+// applyTopology rebuilds the backends for a new set of alphas reported by
+// watcher, unless a reload replaced that watcher in the meantime.
+func (p *Proxy) applyTopology(watcher *discovery.Watcher, topology discovery.Topology) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if watcher != p.discovery {
+		return
+	}
+	old := p.current()
+	next, err := newBackends(discovery.Apply(old.configs, topology), old.configs, old)
+	if err != nil {
+		log.Printf("Warning: Keeping the previous backends, rebuild failed: %v", err)
+		return
+	}
+	next.auth, next.authz, next.limiter = old.auth, old.authz, old.limiter
+	p.backends.Store(next)
+	p.topology = topology
+	old.retire(next)
+}
+
+// retire releases what the replaced backends held and next does not reuse.
+// Outcomes of requests that started on b are reported to next's balancer,
+// which ignores endpoints it does not know.
+func (b *backends) retire(next *backends) {
+	b.stop()
+	for ep, client := range b.clients {
+		if next.clients[ep] != client {
+			log.Printf("| Retiring Dgraph client for %s", ep)
+			client.Retire(clientRetireGrace)
+		}
+	}
+	for _, transport := range b.transports {
+		transport.CloseIdleConnections()
+	}
+}
+
+// stop stops the background work of the balancers, such as health checks.
+func (b *backends) stop() {
+	if stopper, ok := b.balancer.(loadbalancer.Stopper); ok {
+		stopper.Stop()
+	}
+	if stopper, ok := b.purposeful.(loadbalancer.Stopper); ok {
+		stopper.Stop()
+	}
+}
+
+// Close stops discovery and health checks, waits until no Dgraph call is
+// running or ctx is done, and then closes every Dgraph client.
+func (p *Proxy) Close(ctx context.Context) error {
+	p.mu.Lock()
+	if p.discovery != nil {
+		p.discovery.Stop()
+		p.discovery = nil
+	}
+	b := p.current()
+	p.mu.Unlock()
+	if b == nil {
+		return nil
+	}
+	b.stop()
+
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	var err error
+	for b.inflight() > 0 && err == nil {
+		select {
+		case <-ctx.Done():
+			err = fmt.Errorf("closing with %d Dgraph calls still running: %w", b.inflight(), ctx.Err())
+		case <-ticker.C:
+		}
+	}
+
+	for _, client := range b.clients {
+		client.Close()
+	}
+	return err
+}
+
+func (b *backends) inflight() int {
+	n := 0
+	for _, client := range b.clients {
+		n += client.Inflight()
+	}
+	return n
+}
+
+// warnRestartOnly logs the settings a reload cannot apply because they
+// belong to listeners that are already running.
+func warnRestartOnly(old, next config.Config) {
+	if old.ProxyPort != next.ProxyPort || old.WebSocketPort != next.WebSocketPort ||
+		old.HTTPEnabled() != next.HTTPEnabled() || old.WebSocketEnabled() != next.WebSocketEnabled() {
+		log.Printf("Warning: Listener ports and enable_http/enable_websocket only change on restart.")
+	}
+	if old.TLS != next.TLS {
+		log.Printf("Warning: Listener TLS settings only change on restart.")
+	}
+}
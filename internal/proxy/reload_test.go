@@ -0,0 +1,66 @@
+package proxy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/OpenDgraph/Otter/internal/config"
+	"github.com/OpenDgraph/Otter/internal/loadbalancer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReloadReusesClients(t *testing.T) {
+	cfg := config.Config{
+		BalancerType:    "round-robin",
+		DgraphEndpoints: config.EndpointsFromAddrs("localhost:9080", "localhost:9081"),
+	}
+	p, err := New(cfg)
+	require.NoError(t, err)
+	before := p.current()
+
+	cfg.DgraphEndpoints = []config.Endpoint{{Addr: "localhost:9081", Weight: 2}, {Addr: "localhost:9082"}}
+	cfg.BalancerType = "least-inflight"
+	require.NoError(t, p.Reload(cfg))
+	after := p.current()
+
+	require.Same(t, before.clients["localhost:9081"], after.clients["localhost:9081"], "a weight change keeps the connection")
+	require.NotContains(t, after.clients, "localhost:9080")
+	require.Contains(t, after.clients, "localhost:9082")
+
+	info, client, err := p.SelectClient(context.Background())
+	require.NoError(t, err)
+	require.Same(t, after.clients[info.Endpoint], client)
+
+	cfg.DgraphEndpoints = []config.Endpoint{{Addr: "localhost:9081", TLS: config.ClientTLS{Enabled: true}}}
+	require.NoError(t, p.Reload(cfg))
+	require.NotSame(t, after.clients["localhost:9081"], p.current().clients["localhost:9081"])
+
+	cfg.BalancerType = "no-such-balancer"
+	require.Error(t, p.Reload(cfg))
+	require.Equal(t, "least-inflight", p.current().configs.BalancerType, "a failed reload keeps the running config")
+}
+
+func TestOutcomesGoToPickingBalancer(t *testing.T) {
+	cfg := config.Config{
+		BalancerType:    "least-inflight",
+		DgraphEndpoints: config.EndpointsFromAddrs("localhost:9080"),
+	}
+	p, err := New(cfg)
+	require.NoError(t, err)
+	before := p.current().balancer.(*loadbalancer.LeastInflightBalancer)
+	ctx := context.Background()
+
+	old, _, err := p.SelectClient(ctx)
+	require.NoError(t, err)
+	cfg.DgraphEndpoints = []config.Endpoint{{Addr: "localhost:9080", Weight: 2}}
+	require.NoError(t, p.Reload(cfg))
+	after := p.current().balancer.(*loadbalancer.LeastInflightBalancer)
+	node, _, err := p.SelectClient(ctx)
+	require.NoError(t, err)
+
+	p.ReportOutcome(old, loadbalancer.Outcome{})
+	require.Zero(t, before.Inflight("localhost:9080"))
+	require.Equal(t, 1, after.Inflight("localhost:9080"), "the call on the new balancer is still running")
+	p.ReportOutcome(node, loadbalancer.Outcome{})
+	require.Zero(t, after.Inflight("localhost:9080"))
+}
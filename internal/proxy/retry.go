@@ -0,0 +1,154 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"log"
+	"syscall"
+	"time"
+
+	"github.com/OpenDgraph/Otter/internal/auth"
+	"github.com/OpenDgraph/Otter/internal/config"
+	"github.com/OpenDgraph/Otter/internal/dgraph"
+	"github.com/OpenDgraph/Otter/internal/loadbalancer"
+	"github.com/OpenDgraph/Otter/internal/retry"
+	"github.com/dgraph-io/dgo/v240"
+	api "github.com/dgraph-io/dgo/v240/protos/api"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Call is a Dgraph call Run can make again on another client.
+type Call func(ctx context.Context, client *dgraph.Client) (*api.Response, error)
+
+// RetryPolicy says which failures of a call are safe to retry. Calls that
+// never reached the alpha always are. A write that did may have been applied
+// before the connection went away, and Dgraph cannot tell, so it is only
+// retried when the client named it with a key; see RunKeyed.
+type RetryPolicy struct {
+	Idempotent bool // reads, and writes named with a key
+	Aborted    bool // upserts, run again when a transaction conflict aborts them
+}
+
+// IdempotencyHeader is the request header clients name a write with.
+func (p *Proxy) IdempotencyHeader() string {
+	return p.current().configs.Retries.WithDefaults().IdempotencyHeader
+}
+
+// configureRetries applies cfg to the retry budget and the results of keyed
+// writes, which outlive reloads.
+func (p *Proxy) configureRetries(cfg config.RetryConfig) {
+	cfg = cfg.WithDefaults()
+	p.retries.Configure(cfg.Budget, cfg.BudgetBurst)
+	p.idempotency.Configure(cfg.IdempotencyTTL, cfg.IdempotencyKeys)
+}
+
+// RunKeyed is Run for a write the client named with key. It runs once per
+// caller and key: a repeat while it runs, or soon after it succeeded, gets
+// its result instead of applying the write again, and gives up its own pick
+// of node. That makes the write safe to retry. Without a key it is Run.
+func (p *Proxy) RunKeyed(ctx context.Context, key, purpose string, node loadbalancer.EndpointInfo, client *dgraph.Client, policy RetryPolicy, call Call) (*api.Response, error) {
+	if key == "" {
+		return p.Run(ctx, purpose, node, client, policy, call)
+	}
+	policy.Idempotent = true
+	ran := false
+	resp, err := p.idempotency.Do(ctx, idempotencyKey(ctx, key), func() (*api.Response, error) {
+		ran = true
+		return p.Run(ctx, purpose, node, client, policy, call)
+	})
+	if !ran {
+		p.ReportOutcome(node, loadbalancer.Outcome{Refused: true})
+	}
+	return resp, err
+}
+
+// idempotencyKey scopes key to the tenant and caller in ctx, so callers
+// cannot see each other's results.
+func idempotencyKey(ctx context.Context, key string) string {
+	caller := ""
+	if id := auth.IdentityFrom(ctx); id != nil {
+		caller = id.Method + ":" + id.Subject
+	}
+	return TenantFrom(ctx) + "\x00" + caller + "\x00" + key
+}
+
+// Run makes call with client, picked from node for purpose, and reports its
+// outcome. A failure policy makes safe is retried on another endpoint after
+// a jittered backoff, while attempts and the retry budget last and ctx is
+// not done. The error of the last attempt is returned.
+func (p *Proxy) Run(ctx context.Context, purpose string, node loadbalancer.EndpointInfo, client *dgraph.Client, policy RetryPolicy, call Call) (*api.Response, error) {
+	cfg := p.current().configs.Retries.WithDefaults()
+	p.retries.Earn()
+	for attempt := 0; ; attempt++ {
+		start := time.Now()
+		var resp *api.Response
+		var err error
+		if policy.Aborted {
+			resp, err = p.whileAborted(ctx, client, call)
+		} else {
+			resp, err = call(ctx, client)
+		}
+		p.ReportOutcome(node, CallOutcome(ctx, start, resp, err))
+
+		if err == nil || ctx.Err() != nil || attempt >= cfg.Attempts {
+			return resp, err
+		}
+		if !unsent(err) && !(policy.Idempotent && retryable(err)) {
+			return resp, err
+		}
+		if !p.retries.Spend() {
+			log.Printf("| Warning: retry budget spent, not retrying call to %s: %v", node.Endpoint, err)
+			return resp, err
+		}
+		log.Printf("| Retrying call to %s on another endpoint: %v", node.Endpoint, err)
+		if !retry.Sleep(ctx, retry.Backoff(cfg.Backoff, cfg.MaxBackoff, attempt)) {
+			return resp, err
+		}
+		next, nextClient, selectErr := p.reselect(ctx, purpose, node.Endpoint)
+		if selectErr != nil {
+			return resp, err
+		}
+		node, client = next, nextClient
+	}
+}
+
+// whileAborted makes call with client, again as long as a conflicting
+// transaction aborts it and the configured attempts last.
+func (p *Proxy) whileAborted(ctx context.Context, client *dgraph.Client, call Call) (*api.Response, error) {
+	cfg := p.current().configs.Retries.WithDefaults()
+	for attempt := 0; ; attempt++ {
+		resp, err := call(ctx, client)
+		if !errors.Is(err, dgo.ErrAborted) || attempt >= cfg.AbortedUpserts {
+			return resp, err
+		}
+		if !retry.Sleep(ctx, retry.Backoff(cfg.Backoff, cfg.MaxBackoff, attempt)) {
+			return resp, err
+		}
+	}
+}
+
+// reselect picks a client for a retry of a call that failed on avoid. When
+// the balancer hands avoid back, the pick is given up and made once more
+// without the routing key, which would lead to avoid again; with no other
+// endpoint the retry goes to avoid.
+func (p *Proxy) reselect(ctx context.Context, purpose, avoid string) (loadbalancer.EndpointInfo, *dgraph.Client, error) {
+	node, client, err := p.SelectClientAuto(ctx, purpose)
+	if err != nil || node.Endpoint != avoid {
+		return node, client, err
+	}
+	p.ReportOutcome(node, loadbalancer.Outcome{Refused: true})
+	return p.SelectClientAuto(context.WithValue(ctx, routeKeyCtxKey{}, ""), purpose)
+}
+
+// retryable reports whether err says the alpha could not serve the call:
+// it is unavailable or the connection to it was reset.
+func retryable(err error) bool {
+	return status.Code(err) == codes.Unavailable || errors.Is(err, syscall.ECONNRESET)
+}
+
+// unsent reports whether err failed a call before it was sent, as when no
+// connection to the alpha could be set up.
+func unsent(err error) bool {
+	return errors.Is(err, dgraph.ErrNotSent)
+}
@@ -0,0 +1,140 @@
+package proxy
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/OpenDgraph/Otter/internal/config"
+	"github.com/OpenDgraph/Otter/internal/dgraph"
+	"github.com/OpenDgraph/Otter/internal/loadbalancer"
+	"github.com/dgraph-io/dgo/v240"
+	api "github.com/dgraph-io/dgo/v240/protos/api"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestRetries(t *testing.T) {
+	// Nothing listens on the address of a closed listener.
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := lis.Addr().String()
+	lis.Close()
+
+	p, err := New(config.Config{
+		BalancerType:    "round-robin",
+		DgraphEndpoints: config.EndpointsFromAddrs(addr, "localhost:9081"),
+		Retries:         config.RetryConfig{Attempts: 2, BudgetBurst: 2, Backoff: time.Millisecond, AbortedUpserts: 2},
+	})
+	require.NoError(t, err)
+	ctx := context.Background()
+	down := p.current().clients[addr]
+
+	// run makes a call on the alpha that is down, failing with fail or, when
+	// nil, with what the call gets from it, and returns the endpoints it was
+	// made on.
+	run := func(policy RetryPolicy, fail error) ([]string, error) {
+		var calls []string
+		node, client, err := p.SelectClientAuto(ctx, "query")
+		require.NoError(t, err)
+		if node.Endpoint != addr {
+			p.ReportOutcome(node, loadbalancer.Outcome{Refused: true})
+			node, client, err = p.SelectClientAuto(ctx, "query")
+			require.NoError(t, err)
+		}
+		_, err = p.Run(ctx, "query", node, client, policy, func(ctx context.Context, client *dgraph.Client) (*api.Response, error) {
+			if client == down {
+				calls = append(calls, addr)
+				if fail == nil {
+					return client.Mutate(ctx, &api.Mutation{SetNquads: []byte(`_:a <name> "a" .`)})
+				}
+				return nil, fail
+			}
+			calls = append(calls, "localhost:9081")
+			return &api.Response{}, nil
+		})
+		return calls, err
+	}
+	unavailable := status.Error(codes.Unavailable, "connection reset by peer")
+
+	calls, err := run(RetryPolicy{Idempotent: true}, unavailable)
+	require.NoError(t, err)
+	require.Equal(t, []string{addr, "localhost:9081"}, calls, "a read is retried on the other endpoint")
+
+	calls, err = run(RetryPolicy{}, unavailable)
+	require.Error(t, err)
+	require.Len(t, calls, 1, "a write that was sent may have been applied")
+
+	calls, err = run(RetryPolicy{}, nil)
+	require.NoError(t, err)
+	require.Len(t, calls, 2, "a call that was never sent is always safe to retry")
+
+	calls, err = run(RetryPolicy{Idempotent: true}, unavailable)
+	require.Error(t, err)
+	require.Len(t, calls, 1, "the budget is spent")
+
+	aborted := 0
+	resp, err := p.whileAborted(ctx, down, func(context.Context, *dgraph.Client) (*api.Response, error) {
+		if aborted++; aborted < 3 {
+			return nil, dgo.ErrAborted
+		}
+		return &api.Response{}, nil
+	})
+	require.NoError(t, err)
+	require.NotNil(t, resp)
+	require.Equal(t, 3, aborted, "aborted upserts are run again")
+
+	for _, addr := range []string{addr, "localhost:9081"} {
+		_, endpoint, _ := p.concurrency.limiters(loadbalancer.EndpointInfo{Endpoint: addr})
+		require.Zero(t, endpoint.Inflight(), "every attempt was released")
+	}
+}
+
+func TestKeyedWrites(t *testing.T) {
+	p, err := New(config.Config{
+		BalancerType:    "round-robin",
+		DgraphEndpoints: config.EndpointsFromAddrs("localhost:9080", "localhost:9081"),
+		Retries:         config.RetryConfig{Attempts: 2, BudgetBurst: 2, Backoff: time.Millisecond},
+	})
+	require.NoError(t, err)
+	ctx := context.Background()
+	failed := ""
+	calls := 0
+	write := func(ctx context.Context, client *dgraph.Client) (*api.Response, error) {
+		calls++
+		if failed == "" {
+			for addr, c := range p.current().clients {
+				if c == client {
+					failed = addr
+				}
+			}
+			return nil, status.Error(codes.Unavailable, "connection reset by peer")
+		}
+		return &api.Response{Txn: &api.TxnContext{StartTs: uint64(calls)}}, nil
+	}
+	mutate := func(ctx context.Context, key string) (*api.Response, error) {
+		node, client, err := p.SelectClientAuto(ctx, "mutation")
+		require.NoError(t, err)
+		return p.RunKeyed(ctx, key, "mutation", node, client, RetryPolicy{}, write)
+	}
+
+	resp, err := mutate(ctx, "k1")
+	require.NoError(t, err)
+	require.Equal(t, 2, calls, "a keyed write that was sent is retried")
+
+	again, err := mutate(ctx, "k1")
+	require.NoError(t, err)
+	require.Same(t, resp, again, "a repeat gets the result of the write")
+	require.Equal(t, 2, calls, "a repeat does not apply the write again")
+
+	other, err := mutate(WithTenant(ctx, "acme"), "k1")
+	require.NoError(t, err)
+	require.NotSame(t, resp, other, "keys are scoped to the tenant")
+
+	for _, addr := range []string{"localhost:9080", "localhost:9081"} {
+		_, endpoint, _ := p.concurrency.limiters(loadbalancer.EndpointInfo{Endpoint: addr})
+		require.Zero(t, endpoint.Inflight(), "every pick was released")
+	}
+}
@@ -0,0 +1,70 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/OpenDgraph/Otter/internal/admission"
+	"github.com/OpenDgraph/Otter/internal/parsing"
+)
+
+type routeKeyCtxKey struct{}
+
+// WithRouteKey attaches the key consistent-hash balancers route by.
+func WithRouteKey(ctx context.Context, key string) context.Context {
+	if key == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, routeKeyCtxKey{}, key)
+}
+
+// RouteKeyFrom returns the routing key attached to ctx, if any.
+func RouteKeyFrom(ctx context.Context) string {
+	key, _ := ctx.Value(routeKeyCtxKey{}).(string)
+	return key
+}
+
+// RoutesByKey reports whether the configured balancer picks endpoints by
+// routing key, so callers only pay for computing one when it is used.
+func (p *Proxy) RoutesByKey() bool {
+	b := p.current()
+	return b.configs.BalancerType == "consistent-hash" ||
+		(b.purposeful != nil && b.configs.GroupBalancer == "consistent-hash")
+}
+
+// RouteKeyHeader is the request header clients can set to choose their own
+// routing key.
+func (p *Proxy) RouteKeyHeader() string {
+	return p.current().configs.ConsistentHash.WithDefaults().Header
+}
+
+// QueryRouteKey returns the routing key of a DQL query: the client supplied
+// key when there is one, the query's root functions and predicates
+// otherwise, so the same query shape keeps landing on the same alpha.
+func QueryRouteKey(clientKey, query string) string {
+	if clientKey != "" {
+		return clientKey
+	}
+	if shape, err := parsing.QueryShape(query); err == nil {
+		return shape
+	}
+	return query
+}
+
+// routeContext returns the context used to select a backend for r, with
+// the priority the client asked for. A query is given when the request
+// carries one.
+func (p *Proxy) routeContext(r *http.Request, query string) context.Context {
+	ctx := r.Context()
+	if priority, err := admission.ParsePriority(r.Header.Get(p.PriorityHeader())); err == nil {
+		ctx = WithPriority(ctx, priority)
+	}
+	if !p.RoutesByKey() {
+		return ctx
+	}
+	clientKey := r.Header.Get(p.RouteKeyHeader())
+	if query == "" {
+		return WithRouteKey(ctx, clientKey)
+	}
+	return WithRouteKey(ctx, QueryRouteKey(clientKey, query))
+}
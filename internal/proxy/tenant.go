@@ -0,0 +1,280 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+
+	"github.com/OpenDgraph/Otter/internal/auth"
+	"github.com/OpenDgraph/Otter/internal/config"
+	"github.com/OpenDgraph/Otter/internal/dgraph"
+	"github.com/OpenDgraph/Otter/internal/helpers"
+	"github.com/OpenDgraph/Otter/internal/loadbalancer"
+)
+
+var (
+	// ErrTenantDenied is returned when a caller may not use a tenant, or a
+	// purpose within its tenant.
+	ErrTenantDenied = errors.New("tenant not allowed")
+	// ErrTenantBusy is returned when a tenant already runs as many requests
+	// as its max_concurrent allows.
+	ErrTenantBusy = errors.New("tenant is at its concurrency limit")
+)
+
+type tenantKey struct{}
+
+// WithTenant returns ctx carrying the name of the tenant requests made with
+// it belong to.
+func WithTenant(ctx context.Context, name string) context.Context {
+	if name == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, tenantKey{}, name)
+}
+
+// TenantFrom returns the tenant set with WithTenant, or "".
+func TenantFrom(ctx context.Context) string {
+	name, _ := ctx.Value(tenantKey{}).(string)
+	return name
+}
+
+type tenant struct {
+	config.Tenant // with the password read from its file
+	name          string
+	running       *atomic.Int64 // kept across reloads
+}
+
+// tenants holds the configured tenants. A nil *tenants has none.
+type tenants struct {
+	header      string
+	open        bool // no auth method: callers pick any tenant
+	byName      map[string]*tenant
+	byNamespace map[uint64]*tenant
+}
+
+func newTenants(cfg config.Config, old *tenants) (*tenants, error) {
+	if !cfg.Tenancy.Enabled() {
+		return nil, nil
+	}
+	t := &tenants{
+		header:      cfg.Tenancy.WithDefaults().Header,
+		open:        !cfg.Auth.Enabled(),
+		byName:      make(map[string]*tenant, len(cfg.Tenancy.Tenants)),
+		byNamespace: make(map[uint64]*tenant, len(cfg.Tenancy.Tenants)),
+	}
+	for name, tc := range cfg.Tenancy.Tenants {
+		if tc.User == "" {
+			tc.User, tc.Password = cfg.DgraphUser, cfg.DgraphPassword
+		}
+		if tc.PasswordFile != "" {
+			password, err := os.ReadFile(tc.PasswordFile)
+			if err != nil {
+				return nil, fmt.Errorf("reading password of tenant %s: %w", name, err)
+			}
+			tc.Password = strings.TrimSpace(string(password))
+		}
+		tn := &tenant{Tenant: tc, name: name, running: new(atomic.Int64)}
+		if prev := old.get(name); prev != nil {
+			tn.running = prev.running
+		}
+		t.byName[name], t.byNamespace[tc.Namespace] = tn, tn
+	}
+	return t, nil
+}
+
+func (t *tenants) get(name string) *tenant {
+	if t == nil || name == "" {
+		return nil
+	}
+	return t.byName[name]
+}
+
+// resolve returns the tenant of id, who asked for requested. A tenant named
+// by the credentials cannot be changed; otherwise requested is honoured for
+// its members, or for anyone when no auth method is configured.
+func (t *tenants) resolve(id *auth.Identity, requested string) (*tenant, error) {
+	if t == nil {
+		return nil, nil
+	}
+	bound, isBound := "", false
+	if id != nil {
+		bound, isBound = id.Tenant, id.Tenant != ""
+		if !isBound && id.Method == "dgraph" {
+			// A dgraph login is tied to its namespace.
+			if tn, ok := t.byNamespace[id.Namespace]; ok {
+				bound = tn.name
+			}
+			isBound = true
+		}
+	}
+
+	if isBound {
+		if requested != "" && requested != bound {
+			return nil, fmt.Errorf("%w: credentials are bound to tenant %q", ErrTenantDenied, bound)
+		}
+		if bound == "" {
+			return nil, nil
+		}
+		if tn := t.byName[bound]; tn != nil {
+			return tn, nil
+		}
+		return nil, fmt.Errorf("%w: unknown tenant %q", ErrTenantDenied, bound)
+	}
+
+	if requested == "" {
+		return nil, nil
+	}
+	tn := t.byName[requested]
+	if tn == nil {
+		return nil, fmt.Errorf("%w: unknown tenant %q", ErrTenantDenied, requested)
+	}
+	if !t.open && !tn.member(id) {
+		return nil, fmt.Errorf("%w: not a member of tenant %q", ErrTenantDenied, requested)
+	}
+	return tn, nil
+}
+
+// member reports whether id is listed in the members of tn, by subject or
+// by one of its groups.
+func (tn *tenant) member(id *auth.Identity) bool {
+	if id == nil {
+		return false
+	}
+	for _, m := range tn.Members {
+		if m == id.Subject || id.InGroup(m) {
+			return true
+		}
+	}
+	return false
+}
+
+// allows reports whether tn may make calls for purpose.
+func (tn *tenant) allows(purpose string) bool {
+	if tn == nil || len(tn.Purposes) == 0 {
+		return true
+	}
+	for _, p := range tn.Purposes {
+		if p == purpose {
+			return true
+		}
+	}
+	return false
+}
+
+// admit takes a slot of tn's max_concurrent; release gives it back.
+func (tn *tenant) admit() (release func(), err error) {
+	if tn == nil || tn.MaxConcurrent <= 0 {
+		return func() {}, nil
+	}
+	if tn.running.Add(1) > int64(tn.MaxConcurrent) {
+		tn.running.Add(-1)
+		return nil, fmt.Errorf("%w (%d)", ErrTenantBusy, tn.MaxConcurrent)
+	}
+	return func() { tn.running.Add(-1) }, nil
+}
+
+// TenantHeader is the request header naming the tenant.
+func (p *Proxy) TenantHeader() string {
+	if t := p.current().tenants; t != nil {
+		return t.header
+	}
+	return config.DefaultTenantHeader
+}
+
+// ResolveTenant returns the name of the tenant of id, who asked for
+// requested, or "" when it has none.
+func (p *Proxy) ResolveTenant(id *auth.Identity, requested string) (string, error) {
+	tn, err := p.current().tenants.resolve(id, requested)
+	if tn == nil {
+		return "", err
+	}
+	return tn.name, nil
+}
+
+// AdmitTenant counts a request of the tenant name against its
+// max_concurrent quota. release must be called once the request is done.
+func (p *Proxy) AdmitTenant(name string) (release func(), err error) {
+	return p.current().tenants.get(name).admit()
+}
+
+// WithTenants resolves the tenant of every request from the caller's
+// credentials and the tenant header, and answers 403 when the caller may not
+// use it or 429 when the tenant is at its concurrency limit.
+func (p *Proxy) WithTenants(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b := p.current()
+		if b.tenants == nil || r.Method == http.MethodOptions {
+			next.ServeHTTP(w, r)
+			return
+		}
+		tn, err := b.tenants.resolve(auth.IdentityFrom(r.Context()), r.Header.Get(b.tenants.header))
+		if err != nil {
+			helpers.WriteJSONError(w, http.StatusForbidden, err.Error())
+			return
+		}
+		release, err := tn.admit()
+		if err != nil {
+			helpers.WriteJSONError(w, http.StatusTooManyRequests, err.Error())
+			return
+		}
+		defer release()
+		if tn != nil {
+			r = r.WithContext(WithTenant(r.Context(), tn.name))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// checkPurpose refuses purpose when the tenant in ctx does not allow it.
+func (b *backends) checkPurpose(ctx context.Context, purpose string) error {
+	name := TenantFrom(ctx)
+	if !b.tenants.get(name).allows(purpose) {
+		return fmt.Errorf("%w: tenant %q may not run %s", ErrTenantDenied, name, purpose)
+	}
+	return nil
+}
+
+// tenantEndpoint picks the endpoint of a call of the tenant in ctx from the
+// tenant's groups, trying them in order. ok is false when the tenant has no
+// groups of its own.
+func (b *backends) tenantEndpoint(ctx context.Context) (node loadbalancer.EndpointInfo, ok bool, err error) {
+	tn := b.tenants.get(TenantFrom(ctx))
+	if tn == nil || len(tn.Groups) == 0 || b.purposeful == nil {
+		return loadbalancer.EndpointInfo{}, false, nil
+	}
+	var errs []error
+	for _, group := range tn.Groups {
+		node, err := b.nextFromPurposeful(ctx, group)
+		if err == nil {
+			return node, true, nil
+		}
+		errs = append(errs, err)
+	}
+	return loadbalancer.EndpointInfo{}, true, fmt.Errorf("no endpoint for tenant %s: %w", tn.name, errors.Join(errs...))
+}
+
+// credentials returns the Dgraph user calls made for ctx act as, or nil for
+// the shared one: the caller's own user from dgraph_acl, in the namespace of
+// its tenant when the mapping names none, else the login of its tenant. A
+// caller dgraph_acl denies is denied with or without a tenant.
+func (b *backends) credentials(ctx context.Context) (*dgraph.Credentials, error) {
+	creds, err := b.acl.credentials(auth.IdentityFrom(ctx))
+	if err != nil {
+		return nil, err
+	}
+	tn := b.tenants.get(TenantFrom(ctx))
+	if tn == nil {
+		return creds, nil
+	}
+	if creds != nil {
+		if creds.Namespace == 0 {
+			creds.Namespace = tn.Namespace
+		}
+		return creds, nil
+	}
+	return &dgraph.Credentials{User: tn.User, Password: tn.Password, Namespace: tn.Namespace}, nil
+}
@@ -0,0 +1,153 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/OpenDgraph/Otter/internal/auth"
+	"github.com/OpenDgraph/Otter/internal/config"
+	"github.com/OpenDgraph/Otter/internal/dgraph"
+	"github.com/OpenDgraph/Otter/internal/loadbalancer"
+	"github.com/stretchr/testify/require"
+)
+
+func tenancyConfig() config.Config {
+	return config.Config{
+		BalancerType:    "defined",
+		DgraphEndpoints: config.EndpointsFromAddrs("localhost:9080"),
+		Groups: map[string][]config.Endpoint{
+			"query":     config.EndpointsFromAddrs("localhost:9080"),
+			"mutation":  config.EndpointsFromAddrs("localhost:9080"),
+			"acme-pool": config.EndpointsFromAddrs("localhost:9081"),
+		},
+		Auth: config.AuthConfig{APIKeys: []config.APIKey{{Key: "banana", Subject: "demo"}}},
+		Tenancy: config.TenancyConfig{Tenants: map[string]config.Tenant{
+			"acme": {
+				Namespace: 1, User: "groot", Password: "acme-pw",
+				Members: []string{"analysts"}, Purposes: []string{"query"}, Groups: []string{"acme-pool"},
+			},
+			"globex": {Namespace: 2, User: "groot", Password: "globex-pw", MaxConcurrent: 1},
+		}},
+	}
+}
+
+func TestResolveTenant(t *testing.T) {
+	p, err := New(tenancyConfig())
+	require.NoError(t, err)
+
+	resolve := func(id *auth.Identity, requested string) (string, error) {
+		return p.ResolveTenant(id, requested)
+	}
+
+	name, err := resolve(&auth.Identity{Subject: "ci", Tenant: "globex"}, "")
+	require.NoError(t, err)
+	require.Equal(t, "globex", name)
+	_, err = resolve(&auth.Identity{Subject: "ci", Tenant: "globex"}, "acme")
+	require.ErrorIs(t, err, ErrTenantDenied, "credentials bind their tenant")
+	_, err = resolve(&auth.Identity{Subject: "ci", Tenant: "initech"}, "")
+	require.ErrorIs(t, err, ErrTenantDenied)
+
+	name, err = resolve(&auth.Identity{Subject: "bob", Groups: []string{"analysts"}}, "acme")
+	require.NoError(t, err)
+	require.Equal(t, "acme", name)
+	_, err = resolve(&auth.Identity{Subject: "eve"}, "acme")
+	require.ErrorIs(t, err, ErrTenantDenied, "only members pick a tenant")
+	name, err = resolve(&auth.Identity{Subject: "eve"}, "")
+	require.NoError(t, err)
+	require.Empty(t, name)
+
+	name, err = resolve(&auth.Identity{Subject: "groot", Method: "dgraph", Namespace: 2}, "")
+	require.NoError(t, err)
+	require.Equal(t, "globex", name)
+	_, err = resolve(&auth.Identity{Subject: "groot", Method: "dgraph"}, "acme")
+	require.ErrorIs(t, err, ErrTenantDenied, "a dgraph login stays in its namespace")
+
+	open := tenancyConfig()
+	open.Auth = config.AuthConfig{}
+	require.NoError(t, p.Reload(open))
+	name, err = resolve(nil, "acme")
+	require.NoError(t, err)
+	require.Equal(t, "acme", name, "without auth anyone picks a tenant")
+}
+
+func TestTenantRouting(t *testing.T) {
+	p, err := New(tenancyConfig())
+	require.NoError(t, err)
+	b := p.current()
+	ctx := WithTenant(context.Background(), "acme")
+
+	node, _, err := p.selectBackend(ctx, "query")
+	require.NoError(t, err)
+	require.Equal(t, "localhost:9081", node.Endpoint, "acme is served from its own group")
+	p.ReportOutcome(node, loadbalancer.Outcome{})
+
+	_, _, err = p.selectBackend(ctx, "mutation")
+	require.ErrorIs(t, err, ErrTenantDenied)
+	_, _, err = p.SelectClientAuto(ctx, "mutation")
+	require.ErrorIs(t, err, ErrTenantDenied)
+
+	creds, err := b.credentials(ctx)
+	require.NoError(t, err)
+	require.Equal(t, &dgraph.Credentials{User: "groot", Password: "acme-pw", Namespace: 1}, creds)
+	creds, err = b.credentials(context.Background())
+	require.NoError(t, err)
+	require.Nil(t, creds)
+
+	b.acl = &acl{users: []config.ACLUser{
+		{Subject: "alice", User: "alice", Password: "alice-pw"},
+		{Subject: "bob", User: "bob", Password: "bob-pw", Namespace: 3},
+	}, unmatched: config.ACLDeny}
+	creds, err = b.credentials(auth.WithIdentity(ctx, &auth.Identity{Subject: "alice"}))
+	require.NoError(t, err)
+	require.Equal(t, &dgraph.Credentials{User: "alice", Password: "alice-pw", Namespace: 1}, creds, "a user without a namespace gets the tenant's")
+	creds, err = b.credentials(auth.WithIdentity(ctx, &auth.Identity{Subject: "bob"}))
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), creds.Namespace, "the namespace of the mapping is kept")
+	_, err = b.credentials(auth.WithIdentity(ctx, &auth.Identity{Subject: "dave"}))
+	require.ErrorIs(t, err, ErrNoDgraphUser, "a tenant does not lift the denial")
+}
+
+func TestWithTenants(t *testing.T) {
+	p, err := New(tenancyConfig())
+	require.NoError(t, err)
+
+	entered, proceed := make(chan struct{}), make(chan struct{})
+	var seen string
+	handler := p.RequireAuth(p.WithTenants(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = TenantFrom(r.Context())
+		if r.URL.Path == "/slow" {
+			close(entered)
+			<-proceed
+		}
+	})))
+	serve := func(path, tenant string) int {
+		req := httptest.NewRequest(http.MethodPost, path, nil)
+		req.Header.Set("Authorization", "Bearer banana")
+		if tenant != "" {
+			req.Header.Set(config.DefaultTenantHeader, tenant)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	require.Equal(t, http.StatusOK, serve("/query", ""))
+	require.Empty(t, seen)
+	require.Equal(t, http.StatusForbidden, serve("/query", "acme"), "demo is no member of acme")
+
+	// Credentials naming globex, whose quota is one request at a time.
+	cfg := tenancyConfig()
+	cfg.Auth.APIKeys = []config.APIKey{{Key: "banana", Subject: "demo", Tenant: "globex"}}
+	require.NoError(t, p.Reload(cfg))
+
+	done := make(chan int)
+	go func() { done <- serve("/slow", "") }()
+	<-entered
+	require.Equal(t, "globex", seen)
+	require.Equal(t, http.StatusTooManyRequests, serve("/query", ""))
+	close(proceed)
+	require.Equal(t, http.StatusOK, <-done)
+	require.Equal(t, http.StatusOK, serve("/query", ""), "the slot was given back")
+}
@@ -1,8 +1,14 @@
 package proxy
 
 import (
+	"log"
 	"net/http"
+	"net/http/httputil"
 	"strings"
+	"time"
+
+	"github.com/OpenDgraph/Otter/internal/helpers"
+	"github.com/OpenDgraph/Otter/internal/loadbalancer"
 )
 
 func isDQL(src string) bool {
@@ -29,6 +35,36 @@ func enableCORS(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*") // fallback
 	}
 	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, X-Auth-Token, Authorization")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, X-Auth-Token, X-Otter-Token, X-Otter-Tenant, X-Otter-Priority, X-Otter-Idempotency-Key, Authorization")
 	w.Header().Set("Access-Control-Allow-Credentials", "true")
 }
+
+// serveReverseProxy forwards r through rp and reports the outcome of the
+// forwarded call for endpointInfo once it completes.
+func (p *Proxy) serveReverseProxy(rp *httputil.ReverseProxy, endpointInfo loadbalancer.EndpointInfo, w http.ResponseWriter, r *http.Request) {
+	var proxyErr error
+	rp.Transport = p.transport(endpointInfo)
+	rp.ErrorHandler = func(w http.ResponseWriter, req *http.Request, err error) {
+		proxyErr = err
+		log.Printf("| Error proxying to %s: %v", endpointInfo.Endpoint, err)
+		if timedOut(err) {
+			w.WriteHeader(http.StatusGatewayTimeout)
+			return
+		}
+		w.WriteHeader(http.StatusBadGateway)
+	}
+
+	token, err := p.accessToken(r.Context(), endpointInfo)
+	if err != nil {
+		p.ReportOutcome(endpointInfo, loadbalancer.Outcome{Err: err})
+		helpers.WriteJSONError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	if token != "" {
+		setAccessToken(r.Header, token)
+	}
+
+	start := time.Now()
+	rp.ServeHTTP(w, r)
+	p.ReportOutcome(endpointInfo, CallOutcome(r.Context(), start, nil, proxyErr))
+}
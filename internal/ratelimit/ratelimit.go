@@ -0,0 +1,210 @@
+// Package ratelimit keeps token buckets per caller, so one noisy client
+// cannot starve the others.
+package ratelimit
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/OpenDgraph/Otter/internal/config"
+)
+
+// Class is the kind of call a token is taken for.
+type Class string
+
+const (
+	Query    Class = "query"
+	Mutation Class = "mutation"
+	Upsert   Class = "upsert"
+)
+
+// sweepInterval is how often buckets that refilled completely are dropped.
+const sweepInterval = time.Minute
+
+// Keys tell a caller apart, one value per way of keying. Limits keyed by a
+// value that is empty for a call do not apply to it.
+type Keys struct {
+	Subject string
+	Tenant  string
+	IP      string
+	Session string
+}
+
+func (k Keys) get(by string) string {
+	switch by {
+	case config.RateBySubject:
+		return k.Subject
+	case config.RateByTenant:
+		return k.Tenant
+	case config.RateByIP:
+		return k.IP
+	case config.RateBySession:
+		return k.Session
+	}
+	return ""
+}
+
+// Exceeded is the error of a call over its budget. It encodes to the JSON
+// clients receive.
+type Exceeded struct {
+	By         string
+	Class      Class
+	RetryAfter time.Duration
+}
+
+func (e *Exceeded) Error() string {
+	return fmt.Sprintf("rate limit exceeded: %s budget per %s, retry in %s", e.Class, e.By, e.RetryAfter.Round(time.Millisecond))
+}
+
+func (e *Exceeded) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Error        string `json:"error"`
+		By           string `json:"by"`
+		Class        Class  `json:"class"`
+		RetryAfterMs int64  `json:"retry_after_ms"`
+	}{"rate limit exceeded", e.By, e.Class, e.RetryAfter.Milliseconds()})
+}
+
+// RetryAfterSeconds is the Retry-After header value for e, rounded up.
+func (e *Exceeded) RetryAfterSeconds() int {
+	return int(math.Ceil(e.RetryAfter.Seconds()))
+}
+
+// Limiter applies every configured limit. A nil *Limiter admits every call.
+type Limiter struct {
+	limits []*limit
+}
+
+type limit struct {
+	cfg config.RateLimit
+
+	mu      sync.Mutex
+	buckets map[bucketKey]*bucket
+	swept   time.Time
+}
+
+type bucketKey struct {
+	key   string
+	class Class
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	cfg    config.Bucket
+}
+
+// New returns the limiter of limits, or nil when there is none.
+func New(limits []config.RateLimit) *Limiter {
+	if len(limits) == 0 {
+		return nil
+	}
+	l := &Limiter{}
+	for _, cfg := range limits {
+		l.limits = append(l.limits, &limit{cfg: cfg, buckets: make(map[bucketKey]*bucket)})
+	}
+	return l
+}
+
+// Allow takes a token for a call of class from every limit that applies to
+// keys. When one of them has none left, the tokens already taken are given
+// back and the returned *Exceeded says how long until the call would pass.
+func (l *Limiter) Allow(keys Keys, class Class, now time.Time) error {
+	if l == nil {
+		return nil
+	}
+	var taken []token
+	for _, lim := range l.limits {
+		key := keys.get(lim.cfg.By)
+		if key == "" {
+			continue
+		}
+		t, wait := lim.take(key, class, now)
+		if wait > 0 {
+			for _, t := range taken {
+				t.refund(now)
+			}
+			return &Exceeded{By: lim.cfg.By, Class: class, RetryAfter: wait}
+		}
+		if t.bucket != nil {
+			taken = append(taken, t)
+		}
+	}
+	return nil
+}
+
+// token is a token taken from bucket, which belongs to limit.
+type token struct {
+	limit  *limit
+	bucket *bucket
+}
+
+// refund gives t back, under the lock of its limit.
+func (t token) refund(now time.Time) {
+	t.limit.mu.Lock()
+	defer t.limit.mu.Unlock()
+	t.bucket.refill(now)
+	t.bucket.tokens = math.Min(float64(t.bucket.cfg.Burst), t.bucket.tokens+1)
+}
+
+// take takes a token from the bucket of key and class. It returns the token,
+// with no bucket when the class is not limited, or how long until a token
+// is available.
+func (lim *limit) take(key string, class Class, now time.Time) (token, time.Duration) {
+	cfg := budget(lim.cfg.Budgets(key), class)
+	if cfg.Rate <= 0 {
+		return token{}, 0
+	}
+	cfg = cfg.WithDefaults()
+
+	lim.mu.Lock()
+	defer lim.mu.Unlock()
+	lim.sweep(now)
+	k := bucketKey{key, class}
+	b, ok := lim.buckets[k]
+	if !ok {
+		b = &bucket{tokens: float64(cfg.Burst), last: now, cfg: cfg}
+		lim.buckets[k] = b
+	}
+	b.refill(now)
+	if b.tokens < 1 {
+		return token{}, time.Duration((1 - b.tokens) / cfg.Rate * float64(time.Second))
+	}
+	b.tokens--
+	return token{lim, b}, 0
+}
+
+// sweep drops the buckets that have refilled completely, which a new bucket
+// would be as well. lim.mu is held.
+func (lim *limit) sweep(now time.Time) {
+	if now.Sub(lim.swept) < sweepInterval {
+		return
+	}
+	lim.swept = now
+	for k, b := range lim.buckets {
+		b.refill(now)
+		if b.tokens >= float64(b.cfg.Burst) {
+			delete(lim.buckets, k)
+		}
+	}
+}
+
+func (b *bucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(float64(b.cfg.Burst), b.tokens+elapsed*b.cfg.Rate)
+		b.last = now
+	}
+}
+
+func budget(b config.RateBudgets, class Class) config.Bucket {
+	switch class {
+	case Mutation:
+		return b.Mutation
+	case Upsert:
+		return b.Upsert
+	}
+	return b.Query
+}
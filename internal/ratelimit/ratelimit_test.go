@@ -0,0 +1,86 @@
+package ratelimit
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/OpenDgraph/Otter/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAllow(t *testing.T) {
+	l := New([]config.RateLimit{{
+		By:        config.RateBySubject,
+		Query:     config.Bucket{Rate: 2, Burst: 2},
+		Mutation:  config.Bucket{Rate: 1},
+		Overrides: map[string]config.RateBudgets{"big": {Query: config.Bucket{Rate: 100}}},
+	}})
+	now := time.Now()
+	alice := Keys{Subject: "alice"}
+
+	require.NoError(t, l.Allow(alice, Query, now))
+	require.NoError(t, l.Allow(alice, Query, now))
+	err := l.Allow(alice, Query, now)
+	var exceeded *Exceeded
+	require.ErrorAs(t, err, &exceeded)
+	require.Equal(t, &Exceeded{By: "subject", Class: Query, RetryAfter: 500 * time.Millisecond}, exceeded)
+	require.Equal(t, 1, exceeded.RetryAfterSeconds())
+
+	require.NoError(t, l.Allow(alice, Mutation, now), "classes have their own buckets")
+	require.NoError(t, l.Allow(alice, Upsert, now), "a class without a rate is not limited")
+	require.NoError(t, l.Allow(Keys{Subject: "bob"}, Query, now), "callers have their own buckets")
+	require.NoError(t, l.Allow(Keys{IP: "10.0.0.1"}, Query, now), "limits keyed by a missing value do not apply")
+	for range 50 {
+		require.NoError(t, l.Allow(Keys{Subject: "big"}, Query, now))
+	}
+
+	require.NoError(t, l.Allow(alice, Query, now.Add(500*time.Millisecond)), "the bucket refills")
+
+	out, err := json.Marshal(exceeded)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"error":"rate limit exceeded","by":"subject","class":"query","retry_after_ms":500}`, string(out))
+
+	var none *Limiter
+	require.NoError(t, none.Allow(alice, Query, now))
+}
+
+func TestAllowRefundsEarlierLimits(t *testing.T) {
+	l := New([]config.RateLimit{
+		{By: config.RateByIP, Query: config.Bucket{Rate: 1, Burst: 2}},
+		{By: config.RateByTenant, Query: config.Bucket{Rate: 1, Burst: 1}},
+	})
+	now := time.Now()
+
+	require.NoError(t, l.Allow(Keys{IP: "a", Tenant: "acme"}, Query, now))
+	require.Error(t, l.Allow(Keys{IP: "a", Tenant: "acme"}, Query, now))
+	require.NoError(t, l.Allow(Keys{IP: "a", Tenant: "globex"}, Query, now), "the refused call gave its ip token back")
+	require.Error(t, l.Allow(Keys{IP: "a", Tenant: "initech"}, Query, now))
+}
+
+func TestAllowConcurrently(t *testing.T) {
+	l := New([]config.RateLimit{
+		{By: config.RateByIP, Query: config.Bucket{Rate: 1000, Burst: 50}},
+		{By: config.RateByTenant, Query: config.Bucket{Rate: 1, Burst: 1}},
+	})
+	now := time.Now()
+
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				l.Allow(Keys{IP: "a", Tenant: fmt.Sprint("t", i%2)}, Query, now)
+			}
+		}()
+	}
+	wg.Wait()
+
+	for range 48 {
+		require.NoError(t, l.Allow(Keys{IP: "a"}, Query, now), "refused calls gave their ip tokens back")
+	}
+	require.Error(t, l.Allow(Keys{IP: "a"}, Query, now))
+}
@@ -0,0 +1,185 @@
+// Package retry holds what decides how often and when failed calls run
+// again: a budget earned by the calls made and a jittered backoff.
+package retry
+
+import (
+	"context"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// Budget lets retries be a share of the calls made. Every call earns ratio
+// of a retry, up to burst saved; every retry spends one. A zero Budget
+// allows no retry.
+type Budget struct {
+	mu      sync.Mutex
+	ratio   float64
+	burst   float64
+	balance float64
+}
+
+// Configure sets the ratio and burst of b. A budget configured for the
+// first time starts full.
+func (b *Budget) Configure(ratio float64, burst int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.burst == 0 {
+		b.balance = float64(burst)
+	}
+	b.ratio, b.burst = ratio, float64(burst)
+	b.balance = min(b.balance, b.burst)
+}
+
+// Earn records a call.
+func (b *Budget) Earn() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.balance = min(b.burst, b.balance+b.ratio)
+}
+
+// Spend takes a retry from b and reports whether there was one.
+func (b *Budget) Spend() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.balance < 1 {
+		return false
+	}
+	b.balance--
+	return true
+}
+
+// Backoff returns how long to wait before retry number attempt, counted
+// from 0: a random duration up to base doubled attempt times, at most
+// ceiling.
+func Backoff(base, ceiling time.Duration, attempt int) time.Duration {
+	limit := ceiling
+	if attempt < 32 && base<<attempt > 0 && base<<attempt < ceiling {
+		limit = base << attempt
+	}
+	if limit <= 0 {
+		return 0
+	}
+	return rand.N(limit) + 1
+}
+
+// Sleep waits for d, or until ctx is done, and reports whether it waited
+// the whole time.
+func Sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// Dedup runs a call at most once per key. A call for a key whose call is
+// running waits for it and shares its result, as does one made within ttl
+// after it succeeded. Failed calls are forgotten, so their key can be tried
+// again. At most max keys are remembered, all of them when max is 0; when
+// full, the result expiring first is dropped.
+type Dedup[T any] struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	max     int
+	entries map[string]*entry[T]
+	now     func() time.Time
+}
+
+type entry[T any] struct {
+	done    chan struct{} // closed once the call returned
+	result  T
+	err     error
+	expires time.Time // zero while the call runs
+}
+
+// Configure sets how long results are kept and how many keys at most.
+func (d *Dedup[T]) Configure(ttl time.Duration, max int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.ttl, d.max = ttl, max
+}
+
+// Do returns the result of fn, run for key unless another call for key is
+// running or succeeded recently. It fails with the error of ctx when ctx is
+// done while waiting.
+func (d *Dedup[T]) Do(ctx context.Context, key string, fn func() (T, error)) (T, error) {
+	for {
+		d.mu.Lock()
+		d.init()
+		e, ok := d.entries[key]
+		if ok && !e.expires.IsZero() && !d.now().Before(e.expires) {
+			delete(d.entries, key)
+			ok = false
+		}
+		if !ok {
+			e = &entry[T]{done: make(chan struct{})}
+			d.evict()
+			d.entries[key] = e
+			d.mu.Unlock()
+			return d.run(key, e, fn)
+		}
+		d.mu.Unlock()
+
+		select {
+		case <-e.done:
+		case <-ctx.Done():
+			var zero T
+			return zero, ctx.Err()
+		}
+		if e.err == nil {
+			return e.result, nil
+		}
+	}
+}
+
+func (d *Dedup[T]) run(key string, e *entry[T], fn func() (T, error)) (T, error) {
+	e.result, e.err = fn()
+	d.mu.Lock()
+	if e.err != nil {
+		delete(d.entries, key)
+	} else {
+		e.expires = d.now().Add(d.ttl)
+	}
+	close(e.done)
+	d.mu.Unlock()
+	return e.result, e.err
+}
+
+// init prepares a zero Dedup. d.mu is held.
+func (d *Dedup[T]) init() {
+	if d.entries == nil {
+		d.entries = make(map[string]*entry[T])
+	}
+	if d.now == nil {
+		d.now = time.Now
+	}
+}
+
+// evict makes room for one more key: expired results go, then, when still
+// full, the result expiring first. Running calls are kept. d.mu is held.
+func (d *Dedup[T]) evict() {
+	if d.max <= 0 || len(d.entries) < d.max {
+		return
+	}
+	now := d.now()
+	oldest := ""
+	for key, e := range d.entries {
+		if e.expires.IsZero() {
+			continue
+		}
+		if !now.Before(e.expires) {
+			delete(d.entries, key)
+			continue
+		}
+		if oldest == "" || e.expires.Before(d.entries[oldest].expires) {
+			oldest = key
+		}
+	}
+	if len(d.entries) >= d.max && oldest != "" {
+		delete(d.entries, oldest)
+	}
+}
@@ -0,0 +1,97 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBudget(t *testing.T) {
+	var b Budget
+	require.False(t, b.Spend(), "a zero budget allows no retry")
+
+	b.Configure(0.5, 2)
+	require.True(t, b.Spend())
+	require.True(t, b.Spend())
+	require.False(t, b.Spend(), "the burst is spent")
+
+	b.Earn()
+	require.False(t, b.Spend(), "half a retry per call")
+	b.Earn()
+	require.True(t, b.Spend())
+
+	for range 10 {
+		b.Earn()
+	}
+	b.Configure(0.5, 1)
+	require.True(t, b.Spend())
+	require.False(t, b.Spend(), "a smaller burst caps what was saved")
+}
+
+func TestBackoff(t *testing.T) {
+	for attempt := range 40 {
+		d := Backoff(10*time.Millisecond, time.Second, attempt)
+		require.Positive(t, d)
+		require.LessOrEqual(t, d, time.Second)
+		if attempt == 0 {
+			require.LessOrEqual(t, d, 10*time.Millisecond)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.False(t, Sleep(ctx, time.Minute))
+	require.True(t, Sleep(context.Background(), time.Millisecond))
+}
+
+func TestDedup(t *testing.T) {
+	var d Dedup[int]
+	now := time.Unix(1000, 0)
+	d.now = func() time.Time { return now }
+	d.Configure(time.Minute, 2)
+	ctx := context.Background()
+	calls := 0
+	call := func() (int, error) { calls++; return calls, nil }
+
+	release := make(chan struct{})
+	first := make(chan int)
+	go func() {
+		v, _ := d.Do(ctx, "a", func() (int, error) { <-release; return call() })
+		first <- v
+	}()
+	require.Eventually(t, func() bool {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		return len(d.entries) == 1
+	}, time.Second, time.Millisecond)
+	second := make(chan int)
+	go func() {
+		v, _ := d.Do(ctx, "a", call)
+		second <- v
+	}()
+	close(release)
+	require.Equal(t, 1, <-first)
+	require.Equal(t, 1, <-second, "a repeat while the call runs shares its result")
+
+	v, err := d.Do(ctx, "a", call)
+	require.NoError(t, err)
+	require.Equal(t, 1, v, "a repeat after it succeeded gets the result")
+	require.Equal(t, 1, calls)
+
+	now = now.Add(time.Second)
+	_, err = d.Do(ctx, "b", func() (int, error) { return 0, errors.New("down") })
+	require.Error(t, err)
+	v, _ = d.Do(ctx, "b", call)
+	require.Equal(t, 2, v, "failed calls are forgotten")
+
+	d.Do(ctx, "c", call)
+	v, _ = d.Do(ctx, "a", call)
+	require.Equal(t, 4, v, "the oldest result made room for c")
+
+	now = now.Add(2 * time.Minute)
+	v, _ = d.Do(ctx, "c", call)
+	require.Equal(t, 5, v, "results expire")
+}
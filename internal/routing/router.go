@@ -7,7 +7,9 @@ import (
 	"github.com/OpenDgraph/Otter/internal/proxy"
 )
 
-func SetupRoutes(p *proxy.Proxy) *http.ServeMux {
+// SetupRoutes returns the HTTP proxy routes, behind the authentication
+// configured in auth.http.
+func SetupRoutes(p *proxy.Proxy) http.Handler {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/query", p.HandleQuery)
 	mux.HandleFunc("/mutate", p.HandleMutation)
@@ -20,5 +22,5 @@ func SetupRoutes(p *proxy.Proxy) *http.ServeMux {
 	mux.HandleFunc("/admin/schema", p.HandleDirect)
 	mux.HandleFunc("/state", p.HandleDirect)
 	mux.HandleFunc("/", p.HandleFrontend)
-	return mux
+	return p.RequireAuth(p.WithTenants(mux))
 }
@@ -0,0 +1,102 @@
+// Package server runs Otter's listeners and stops them together.
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+)
+
+// Group supervises a set of HTTP servers: they are started together, the
+// first one to fail is reported by Wait, and Shutdown drains them all.
+type Group struct {
+	servers []*named
+	failed  chan error
+}
+
+type named struct {
+	name string
+	srv  *http.Server
+}
+
+func NewGroup() *Group {
+	return &Group{}
+}
+
+// Add registers srv under name, used in logs and errors. Servers with a
+// TLSConfig serve TLS with its certificates.
+func (g *Group) Add(name string, srv *http.Server) {
+	g.servers = append(g.servers, &named{name: name, srv: srv})
+}
+
+// Len returns the number of registered servers.
+func (g *Group) Len() int {
+	return len(g.servers)
+}
+
+// Start binds every server before serving any of them, so a port already in
+// use fails the whole group instead of leaving it half running.
+func (g *Group) Start() error {
+	listeners := make([]net.Listener, 0, len(g.servers))
+	for _, s := range g.servers {
+		ln, err := net.Listen("tcp", s.srv.Addr)
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return fmt.Errorf("%s server: %w", s.name, err)
+		}
+		listeners = append(listeners, ln)
+	}
+
+	g.failed = make(chan error, len(g.servers))
+	for i, s := range g.servers {
+		go func(s *named, ln net.Listener) {
+			var err error
+			if s.srv.TLSConfig != nil {
+				log.Printf("Starting %s server on %s with TLS", s.name, ln.Addr())
+				err = s.srv.ServeTLS(ln, "", "")
+			} else {
+				log.Printf("Starting %s server on %s", s.name, ln.Addr())
+				err = s.srv.Serve(ln)
+			}
+			if !errors.Is(err, http.ErrServerClosed) {
+				g.failed <- fmt.Errorf("%s server: %w", s.name, err)
+			}
+		}(s, listeners[i])
+	}
+	return nil
+}
+
+// Wait blocks until ctx is done, returning nil, or until a server stops on
+// its own, returning why.
+func (g *Group) Wait(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		return nil
+	case err := <-g.failed:
+		return err
+	}
+}
+
+// Shutdown gracefully stops every server concurrently: listeners close at
+// once and requests in progress may finish until ctx is done.
+func (g *Group) Shutdown(ctx context.Context) error {
+	errs := make([]error, len(g.servers))
+	var wg sync.WaitGroup
+	for i, s := range g.servers {
+		wg.Add(1)
+		go func(i int, s *named) {
+			defer wg.Done()
+			if err := s.srv.Shutdown(ctx); err != nil {
+				errs[i] = fmt.Errorf("%s server did not drain: %w", s.name, err)
+			}
+		}(i, s)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
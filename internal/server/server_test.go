@@ -0,0 +1,71 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func freeAddr(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	return ln.Addr().String()
+}
+
+func hello(name string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, name)
+	})
+}
+
+func get(t *testing.T, addr string) string {
+	resp, err := http.Get("http://" + addr)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return string(body)
+}
+
+func TestGroupServesUntilCancelled(t *testing.T) {
+	httpAddr, wsAddr := freeAddr(t), freeAddr(t)
+	g := NewGroup()
+	g.Add("HTTP", &http.Server{Addr: httpAddr, Handler: hello("http")})
+	g.Add("WebSocket", &http.Server{Addr: wsAddr, Handler: hello("ws")})
+	require.NoError(t, g.Start())
+
+	assert.Equal(t, "http", get(t, httpAddr))
+	assert.Equal(t, "ws", get(t, wsAddr))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.NoError(t, g.Wait(ctx))
+	require.NoError(t, g.Shutdown(context.Background()))
+
+	_, err := http.Get("http://" + httpAddr)
+	assert.Error(t, err, "listener closed after shutdown")
+}
+
+func TestGroupStartFailsWhenPortTaken(t *testing.T) {
+	taken, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer taken.Close()
+
+	free := freeAddr(t)
+	g := NewGroup()
+	g.Add("HTTP", &http.Server{Addr: free, Handler: hello("http")})
+	g.Add("WebSocket", &http.Server{Addr: taken.Addr().String(), Handler: hello("ws")})
+	require.ErrorContains(t, g.Start(), "WebSocket server")
+
+	// The listener bound before the failure was released.
+	ln, err := net.Listen("tcp", free)
+	require.NoError(t, err)
+	ln.Close()
+}
@@ -0,0 +1,110 @@
+package tlsconfig
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/OpenDgraph/Otter/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+type keyPair struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// issue creates a certificate for name signed by parent, or self-signed CA
+// when parent is nil, and writes it as name.crt and name.key in dir.
+func issue(t *testing.T, dir, name string, parent *keyPair) *keyPair {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	signer := &keyPair{cert: tmpl, key: key}
+	if parent == nil {
+		tmpl.IsCA, tmpl.BasicConstraintsValid = true, true
+		tmpl.KeyUsage = x509.KeyUsageCertSign
+	} else {
+		signer = parent
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer.cert, &key.PublicKey, signer.key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	write := func(file, kind string, der []byte) {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, file), pem.EncodeToMemory(&pem.Block{Type: kind, Bytes: der}), 0600))
+	}
+	write(name+".crt", "CERTIFICATE", der)
+	write(name+".key", "EC PRIVATE KEY", keyDER)
+	return &keyPair{cert: cert, key: key}
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := issue(t, dir, "ca", nil)
+	issue(t, dir, "server", ca)
+	issue(t, dir, "client", ca)
+	path := func(name string) string { return filepath.Join(dir, name) }
+
+	serverTLS, err := Server(config.ServerTLS{CertFile: path("server.crt"), KeyFile: path("server.key"), ClientCAFile: path("ca.crt")})
+	require.NoError(t, err)
+	require.Equal(t, tls.RequireAndVerifyClientCert, serverTLS.ClientAuth)
+
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", serverTLS)
+	require.NoError(t, err)
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})}
+	go srv.Serve(ln)
+	defer srv.Close()
+
+	get := func(c config.ClientTLS) error {
+		clientTLS, err := Client(c)
+		if err != nil {
+			return err
+		}
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: clientTLS}}
+		resp, err := client.Get("https://" + ln.Addr().String())
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+
+	require.NoError(t, get(config.ClientTLS{Enabled: true, CAFile: path("ca.crt"), CertFile: path("client.crt"), KeyFile: path("client.key")}))
+	require.Error(t, get(config.ClientTLS{Enabled: true, CAFile: path("ca.crt")}), "a client certificate is required")
+	require.Error(t, get(config.ClientTLS{Enabled: true, CertFile: path("client.crt"), KeyFile: path("client.key")}), "the private CA is not a system root")
+}
+
+func TestDisabled(t *testing.T) {
+	clientTLS, err := Client(config.ClientTLS{CAFile: "ignored.crt"})
+	require.NoError(t, err)
+	require.Nil(t, clientTLS)
+
+	serverTLS, err := Server(config.ServerTLS{})
+	require.NoError(t, err)
+	require.Nil(t, serverTLS)
+
+	_, err = Client(config.ClientTLS{Enabled: true, CAFile: filepath.Join(t.TempDir(), "missing.crt")})
+	require.ErrorContains(t, err, "loading CA bundle")
+}
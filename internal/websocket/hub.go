@@ -0,0 +1,140 @@
+package websocket
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/OpenDgraph/Otter/internal/admission"
+	"github.com/OpenDgraph/Otter/internal/auth"
+	"github.com/gorilla/websocket"
+)
+
+// closeWriteTimeout bounds the write of a close frame to a slow client.
+const closeWriteTimeout = time.Second
+
+// Hub tracks the open WebSocket sessions so they can be drained on shutdown:
+// idle sessions get a "going away" close frame right away, busy ones once
+// the message they are handling has been answered.
+type Hub struct {
+	mu       sync.Mutex
+	sessions map[*session]struct{}
+	joined   uint64 // sessions ever joined, numbering them
+	closing  bool
+	done     chan struct{} // closed when closing and no session is left
+}
+
+type session struct {
+	id      string // keys the session's rate limits
+	conn    *websocket.Conn
+	ctx     context.Context // done once the client is gone
+	cancel  context.CancelFunc
+	busy    bool
+	goodbye bool // close frame already sent
+
+	// Owned by the goroutine serving the session.
+	identity     *auth.Identity
+	tenant       string
+	wantTenant   string             // asked for on the upgrade request
+	priority     admission.Priority // of messages naming none
+	authFailures int
+	release      func()             // tenant slot held by the message being handled
+	endCall      context.CancelFunc // deadline of the message being handled
+}
+
+func NewHub() *Hub {
+	return &Hub{
+		sessions: make(map[*session]struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// join registers conn. It returns nil when the hub is shutting down.
+func (h *Hub) join(conn *websocket.Conn) *session {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closing {
+		return nil
+	}
+	h.joined++
+	s := &session{id: strconv.FormatUint(h.joined, 10), conn: conn}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	h.sessions[s] = struct{}{}
+	return s
+}
+
+func (h *Hub) leave(s *session) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.sessions, s)
+	if h.closing && len(h.sessions) == 0 {
+		close(h.done)
+	}
+}
+
+// begin marks s busy with a message. It returns false when the hub is
+// shutting down and the message must not be handled.
+func (h *Hub) begin(s *session) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closing {
+		return false
+	}
+	s.busy = true
+	return true
+}
+
+// end marks s idle again, saying goodbye if a shutdown started meanwhile.
+func (h *Hub) end(s *session) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s.busy = false
+	if h.closing {
+		s.goodbyeLocked()
+	}
+}
+
+func (s *session) goodbyeLocked() {
+	if s.goodbye {
+		return
+	}
+	s.goodbye = true
+	msg := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+	if err := s.conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(closeWriteTimeout)); err != nil {
+		log.Printf("| Error sending close frame to %s: %v", s.conn.RemoteAddr(), err)
+	}
+}
+
+// Shutdown stops accepting sessions, asks every client to go away and
+// waits for them to disconnect. Sessions still open when ctx is done are
+// closed without waiting further.
+func (h *Hub) Shutdown(ctx context.Context) error {
+	h.mu.Lock()
+	if !h.closing {
+		h.closing = true
+		if len(h.sessions) == 0 {
+			close(h.done)
+		}
+	}
+	for s := range h.sessions {
+		if !s.busy {
+			s.goodbyeLocked()
+		}
+	}
+	log.Printf("| Draining %d WebSocket sessions", len(h.sessions))
+	h.mu.Unlock()
+
+	select {
+	case <-h.done:
+		return nil
+	case <-ctx.Done():
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		for s := range h.sessions {
+			s.conn.Close()
+		}
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,98 @@
+package websocket
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// echoServer serves sessions the way HandleWebSocketWithProxy does, echoing
+// every message back.
+func echoServer(t *testing.T, hub *Hub) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		require.NoError(t, err)
+		sess := hub.join(conn)
+		if sess == nil {
+			conn.Close()
+			return
+		}
+		defer func() {
+			hub.leave(sess)
+			conn.Close()
+		}()
+		for {
+			hub.end(sess)
+			_, msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if !hub.begin(sess) {
+				continue
+			}
+			conn.WriteMessage(websocket.TextMessage, msg)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func dial(t *testing.T, srv *httptest.Server) *websocket.Conn {
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestHubShutdownSaysGoingAway(t *testing.T) {
+	hub := NewHub()
+	conn := dial(t, echoServer(t, hub))
+
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte("ping")))
+	_, msg, err := conn.ReadMessage()
+	require.NoError(t, err)
+	assert.Equal(t, "ping", string(msg))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	shutdown := make(chan error, 1)
+	go func() { shutdown <- hub.Shutdown(ctx) }()
+
+	// The client reads the close frame; gorilla answers it and the server
+	// side of the session ends.
+	_, _, err = conn.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseGoingAway), "got %v", err)
+	assert.NoError(t, <-shutdown)
+}
+
+func TestHubShutdownTimesOut(t *testing.T) {
+	hub := NewHub()
+	srv := echoServer(t, hub)
+	dial(t, srv) // never reads, so never answers the close frame
+
+	require.Eventually(t, func() bool {
+		hub.mu.Lock()
+		defer hub.mu.Unlock()
+		return len(hub.sessions) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, hub.Shutdown(ctx), context.DeadlineExceeded)
+
+	// New sessions are refused once shutting down.
+	conn := dial(t, srv)
+	_, _, err := conn.ReadMessage()
+	assert.Error(t, err)
+}
+
+func TestHubShutdownWithoutSessions(t *testing.T) {
+	assert.NoError(t, NewHub().Shutdown(context.Background()))
+}
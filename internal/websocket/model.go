@@ -9,7 +9,14 @@ type WSMessage struct {
 	Cond      string `json:"cond,omitempty"` // Optional for upsert
 	CommitNow bool   `json:"commitNow,omitempty"`
 	Verbose   bool   `json:"verbose,omitempty"`
-	Token     string `json:"token,omitempty"`
+	Token     string `json:"token,omitempty"` // API key or JWT
+	User      string `json:"user,omitempty"`  // Dgraph ACL login, with Password
+	Password  string `json:"password,omitempty"`
+	Tenant    string `json:"tenant,omitempty"`   // tenant picked on auth
+	Priority  string `json:"priority,omitempty"` // batch, interactive or critical
+	Timeout   string `json:"timeout,omitempty"`  // such as 2s, capped by the configuration
+
+	IdempotencyKey string `json:"idempotencyKey,omitempty"` // runs a mutation or upsert once, making it safe to retry
 }
 
 type WSResponse struct {
@@ -1,49 +1,118 @@
 package websocket
 
 import (
+	"context"
 	"encoding/json"
 	"log"
 	"time"
 
+	"github.com/OpenDgraph/Otter/internal/admission"
+	"github.com/OpenDgraph/Otter/internal/auth"
+	"github.com/OpenDgraph/Otter/internal/authz"
+	"github.com/OpenDgraph/Otter/internal/proxy"
 	"github.com/gorilla/websocket"
 )
 
 const maxAuthAttempts = 8
 
-func (m *WSMessage) ValidatePapers(conn *websocket.Conn) bool {
-	authAttempts := 0
-
-	for authAttempts < maxAuthAttempts {
-		if !IsValidToken(m.Token) {
-			authAttempts++
-			conn.WriteMessage(websocket.TextMessage, []byte(`{"error":"papers please!"}`))
-			// time.Sleep(3 * time.Second)
-			if authAttempts >= maxAuthAttempts {
-				time.Sleep(3 * time.Second)
-				conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "Go away! bye!"))
-				conn.Close()
-				return false
-			}
-
-			_, msgBytes, err := conn.ReadMessage()
-			if err != nil {
-				log.Printf("| Error reading auth retry: %v\n", err)
-				return false
-			}
-			err = json.Unmarshal(msgBytes, m)
-			if err != nil {
-				conn.WriteMessage(websocket.TextMessage, []byte(`{"error":"invalid JSON"}`))
-				continue
-			}
-		} else {
-			conn.WriteMessage(websocket.TextMessage, []byte(`{"status":"authenticated"}`))
-			return true
+// authenticate handles an auth or login message. The identity is attached
+// to the session, replacing any previous one. A session failing
+// maxAuthAttempts times in a row is closed.
+func (s *session) authenticate(p *proxy.Proxy, msg *WSMessage) {
+	creds := auth.Credentials{Token: msg.Token, User: msg.User, Password: msg.Password}
+	id, err := p.Authenticate(context.Background(), creds)
+	if err != nil {
+		s.authFailures++
+		log.Printf("| Authentication failed for %s: %v", s.conn.RemoteAddr(), err)
+		if s.authFailures >= maxAuthAttempts {
+			msg := websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "Go away! bye!")
+			s.conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(closeWriteTimeout))
+			s.conn.Close()
+			return
 		}
+		s.conn.WriteMessage(websocket.TextMessage, []byte(`{"error":"invalid credentials"}`))
+		return
 	}
 
-	return false
+	s.authFailures = 0
+	requested := msg.Tenant
+	if requested == "" {
+		requested = s.wantTenant
+	}
+	tenant, err := p.ResolveTenant(id, requested)
+	if err != nil {
+		out, _ := json.Marshal(WSResponse{Error: err.Error()})
+		s.conn.WriteMessage(websocket.TextMessage, out)
+		return
+	}
+	s.identity, s.tenant = id, tenant
+	log.Printf("| %s authenticated as %s (%s)", s.conn.RemoteAddr(), id.Subject, id.Method)
+	reply := map[string]interface{}{"status": "authenticated", "subject": id.Subject, "groups": id.Groups}
+	if tenant != "" {
+		reply["tenant"] = tenant
+	}
+	out, _ := json.Marshal(reply)
+	s.conn.WriteMessage(websocket.TextMessage, out)
+}
+
+// checkAuth tells the client to authenticate first when s has no identity,
+// and answers the denial when the policy does not allow op or the caller is
+// over its rate limit. Otherwise the message takes a slot of the session
+// tenant's quota, given back by finish.
+func (s *session) checkAuth(p *proxy.Proxy, op authz.Operation) bool {
+	if s.identity == nil {
+		s.conn.WriteMessage(websocket.TextMessage, []byte(`{"error":"papers please!"}`))
+		return false
+	}
+	if err := p.Authorize(s.context(), op); err != nil {
+		log.Printf("| %s: %v", s.conn.RemoteAddr(), err)
+		out, _ := json.Marshal(err)
+		s.conn.WriteMessage(websocket.TextMessage, out)
+		return false
+	}
+	if err := p.AllowRate(s.context(), op, s.conn.RemoteAddr().String(), s.id); err != nil {
+		out, _ := json.Marshal(err)
+		s.conn.WriteMessage(websocket.TextMessage, out)
+		return false
+	}
+	release, err := p.AdmitTenant(s.tenant)
+	if err != nil {
+		out, _ := json.Marshal(WSResponse{Error: err.Error()})
+		s.conn.WriteMessage(websocket.TextMessage, out)
+		return false
+	}
+	s.release = release
+	return true
+}
+
+// finish releases what the last message held.
+func (s *session) finish() {
+	if s.release != nil {
+		s.release()
+		s.release = nil
+	}
+	if s.endCall != nil {
+		s.endCall()
+		s.endCall = nil
+	}
+}
+
+// context returns the context of the Dgraph calls made for s, carrying the
+// session's identity and tenant. It is done once the client is gone.
+func (s *session) context() context.Context {
+	return proxy.WithTenant(auth.WithIdentity(s.ctx, s.identity), s.tenant)
 }
 
-func IsValidToken(token string) bool {
-	return token == "banana"
+// call returns the context of the Dgraph calls made for msg with purpose:
+// the session's, at the priority of msg and ending at its deadline, which
+// finish releases. msg was validated.
+func (s *session) call(p *proxy.Proxy, purpose string, msg *WSMessage) context.Context {
+	priority := s.priority
+	if msg.Priority != "" {
+		priority, _ = admission.ParsePriority(msg.Priority)
+	}
+	requested, _ := proxy.ParseTimeout(msg.Timeout)
+	ctx, cancel := p.WithTimeout(proxy.WithPriority(s.context(), priority), "", purpose, requested)
+	s.endCall = cancel
+	return ctx
 }
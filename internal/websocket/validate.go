@@ -4,6 +4,8 @@ import (
 	"errors"
 	"fmt"
 
+	"github.com/OpenDgraph/Otter/internal/admission"
+	"github.com/OpenDgraph/Otter/internal/proxy"
 	"github.com/gorilla/websocket"
 )
 
@@ -24,12 +26,19 @@ func (m *WSMessage) validate(conn *websocket.Conn) error {
 		return errors.New(msg)
 	}
 
+	if _, err := admission.ParsePriority(m.Priority); err != nil {
+		return send("unknown priority field")
+	}
+	if _, err := proxy.ParseTimeout(m.Timeout); err != nil {
+		return send("invalid timeout field")
+	}
+
 	switch m.Type {
 	case "":
 		return send("missing type field")
 	case TypeAuth, TypeLogin:
-		if m.Token == "" {
-			return send("missing token field")
+		if m.Token == "" && m.User == "" {
+			return send("missing token or user field")
 		}
 	case TypeLogout, TypeState, TypePing:
 		return nil
@@ -6,7 +6,11 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"time"
 
+	"github.com/OpenDgraph/Otter/internal/admission"
+	"github.com/OpenDgraph/Otter/internal/authz"
+	"github.com/OpenDgraph/Otter/internal/dgraph"
 	"github.com/OpenDgraph/Otter/internal/proxy"
 	"github.com/dgraph-io/dgo/v240/protos/api"
 	"github.com/gorilla/websocket"
@@ -22,15 +26,18 @@ var upgrader = websocket.Upgrader{
 	},
 }
 
-func checkAuth(authenticated bool, conn *websocket.Conn) bool {
-	if !authenticated {
-		conn.WriteMessage(websocket.TextMessage, []byte(`{"error":"papers please!"}`))
-		return false
+// queryRouteContext returns ctx as used to pick the backend of a query
+// message, keyed by the query shape unless the session pinned its own key.
+func queryRouteContext(ctx context.Context, p *proxy.Proxy, sessionKey, query string) context.Context {
+	if !p.RoutesByKey() {
+		return ctx
 	}
-	return true
+	return proxy.WithRouteKey(ctx, proxy.QueryRouteKey(sessionKey, query))
 }
 
-func HandleWebSocketWithProxy(p *proxy.Proxy) http.HandlerFunc {
+// HandleWebSocketWithProxy serves WebSocket sessions on top of p. Sessions
+// are registered with hub so a shutdown can drain them.
+func HandleWebSocketWithProxy(p *proxy.Proxy, hub *Hub) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		conn, err := upgrader.Upgrade(w, r, nil)
 		if err != nil {
@@ -38,22 +45,66 @@ func HandleWebSocketWithProxy(p *proxy.Proxy) http.HandlerFunc {
 			http.Error(w, "WebSocket upgrade failed", http.StatusBadRequest)
 			return
 		}
+		sess := hub.join(conn)
+		if sess == nil {
+			msg := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+			conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(closeWriteTimeout))
+			conn.Close()
+			return
+		}
 		defer func() {
 			log.Printf("| Closing connection: %s\n", conn.RemoteAddr())
+			sess.finish()
+			hub.leave(sess)
 			conn.Close()
 		}()
 
 		log.Printf("| Client connected: %s\n", conn.RemoteAddr())
 
-		authenticated := false
+		// A route key sent on the upgrade request pins the whole session.
+		var sessionKey string
+		if p.RoutesByKey() {
+			sessionKey = r.Header.Get(p.RouteKeyHeader())
+		}
+		sess.wantTenant = r.Header.Get(p.TenantHeader())
+		sess.priority, _ = admission.ParsePriority(r.Header.Get(p.PriorityHeader()))
+
+		// Reading goes on while a message is handled, so a client going
+		// away cancels the Dgraph calls still made for it.
+		messages := make(chan []byte)
+		go func() {
+			defer close(messages)
+			defer sess.cancel()
+			for {
+				_, msgBytes, err := conn.ReadMessage()
+				if err != nil {
+					log.Printf("| Error reading message: %v\n", err)
+					return
+				}
+				select {
+				case messages <- msgBytes:
+				case <-sess.ctx.Done():
+					return
+				}
+			}
+		}()
 
 		for {
-			_, msgBytes, err := conn.ReadMessage()
-			if err != nil {
-				log.Printf("| Error reading message: %v\n", err)
+			// Every message ends by coming back here: the session is idle
+			// again and says goodbye if a shutdown started meanwhile.
+			sess.finish()
+			hub.end(sess)
+
+			msgBytes, ok := <-messages
+			if !ok {
 				break
 			}
 
+			if !hub.begin(sess) {
+				// Shutting down: the client was asked to go away.
+				continue
+			}
+
 			var msg WSMessage
 			if err := json.Unmarshal(msgBytes, &msg); err != nil {
 				conn.WriteMessage(websocket.TextMessage, fmt.Appendf(nil, `{"error":"invalid JSON: %v"}`, err))
@@ -69,28 +120,31 @@ func HandleWebSocketWithProxy(p *proxy.Proxy) http.HandlerFunc {
 			case TypePing:
 				conn.WriteMessage(websocket.TextMessage, []byte(`{"status":"pong"}`))
 
-			case TypeAuth:
-				if IsValidToken(msg.Token) {
-					authenticated = true
-					conn.WriteMessage(websocket.TextMessage, []byte(`{"status":"authenticated"}`))
-				} else {
-					conn.WriteMessage(websocket.TextMessage, []byte(`{"error":"invalid token"}`))
-				}
+			case TypeAuth, TypeLogin:
+				sess.authenticate(p, &msg)
 				continue
 
+			case TypeLogout:
+				sess.identity, sess.tenant = nil, ""
+				conn.WriteMessage(websocket.TextMessage, []byte(`{"status":"logged out"}`))
+
 			case TypeQuery:
-				isAuthorized := checkAuth(authenticated, conn)
-				if !isAuthorized {
+				if !sess.checkAuth(p, authz.Read) {
 					continue
 				}
 
-				_, client, err := p.SelectClientAuto("query")
+				purpose := p.QueryPurpose(msg.Query)
+				ctx := sess.call(p, purpose, &msg)
+				ctx = queryRouteContext(ctx, p, sessionKey, msg.Query)
+				endpointInfo, client, err := p.SelectClientAuto(ctx, purpose)
 				if err != nil {
 					conn.WriteMessage(websocket.TextMessage, fmt.Appendf(nil, `{"error":"%v"}`, err))
 					continue
 				}
 
-				resp, err := client.Query(context.Background(), msg.Query)
+				resp, err := p.Run(ctx, purpose, endpointInfo, client, proxy.RetryPolicy{Idempotent: true}, func(ctx context.Context, client *dgraph.Client) (*api.Response, error) {
+					return client.Query(ctx, msg.Query)
+				})
 				if err != nil {
 					conn.WriteMessage(websocket.TextMessage, fmt.Appendf(nil, `{"error":"%v"}`, err))
 					continue
@@ -109,21 +163,25 @@ func HandleWebSocketWithProxy(p *proxy.Proxy) http.HandlerFunc {
 				}
 
 			case TypeMutation:
-				isAuthorized := checkAuth(authenticated, conn)
-				if !isAuthorized {
+				if !sess.checkAuth(p, authz.Mutate) {
 					continue
 				}
-				_, client, err := p.SelectClientAuto("mutation")
+				m := &api.Mutation{
+					SetNquads: []byte(msg.Mutation),
+					CommitNow: msg.CommitNow,
+				}
+				purpose := p.MutationPurpose(m)
+				ctx := sess.call(p, purpose, &msg)
+				ctx = proxy.WithRouteKey(ctx, sessionKey)
+				endpointInfo, client, err := p.SelectClientAuto(ctx, purpose)
 				if err != nil {
 					conn.WriteMessage(websocket.TextMessage, fmt.Appendf(nil, `{"error":"%v"}`, err))
 					continue
 				}
 
-				m := &api.Mutation{
-					SetNquads: []byte(msg.Mutation),
-					CommitNow: msg.CommitNow,
-				}
-				resp, err := client.Mutate(context.Background(), m)
+				resp, err := p.RunKeyed(ctx, msg.IdempotencyKey, purpose, endpointInfo, client, proxy.RetryPolicy{}, func(ctx context.Context, client *dgraph.Client) (*api.Response, error) {
+					return client.Mutate(ctx, m)
+				})
 				if err != nil {
 					conn.WriteMessage(websocket.TextMessage, fmt.Appendf(nil, `{"error":"%v"}`, err))
 					continue
@@ -148,11 +206,12 @@ func HandleWebSocketWithProxy(p *proxy.Proxy) http.HandlerFunc {
 				}
 
 			case TypeUpsert:
-				isAuthorized := checkAuth(authenticated, conn)
-				if !isAuthorized {
+				if !sess.checkAuth(p, authz.Upsert) {
 					continue
 				}
-				_, client, err := p.SelectClientAuto("upsert")
+				ctx := sess.call(p, "upsert", &msg)
+				ctx = proxy.WithRouteKey(ctx, sessionKey)
+				endpointInfo, client, err := p.SelectClientAuto(ctx, "upsert")
 				if err != nil {
 					conn.WriteMessage(websocket.TextMessage, []byte(`{"error":"%v"}`))
 					continue
@@ -165,7 +224,9 @@ func HandleWebSocketWithProxy(p *proxy.Proxy) http.HandlerFunc {
 					mu.Cond = msg.Cond
 				}
 
-				resp, err := client.Upsert(context.Background(), msg.Query, []*api.Mutation{mu}, msg.CommitNow)
+				resp, err := p.RunKeyed(ctx, msg.IdempotencyKey, "upsert", endpointInfo, client, proxy.RetryPolicy{Aborted: true}, func(ctx context.Context, client *dgraph.Client) (*api.Response, error) {
+					return client.Upsert(ctx, msg.Query, []*api.Mutation{mu}, msg.CommitNow)
+				})
 				if err != nil {
 					out := WSResponse{Error: err.Error()}
 					b, _ := json.Marshal(out)